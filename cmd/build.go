@@ -6,8 +6,12 @@ import (
 )
 
 var (
-	flagBuildClean      bool
-	flagBuildAllowDirty bool
+	flagBuildClean       bool
+	flagBuildAllowDirty  bool
+	flagBuildParallelism int
+	flagBuildTargets     []string
+
+	flagBuildVerifyReproducible bool
 )
 
 var buildCmd = &cobra.Command{
@@ -16,8 +20,12 @@ var buildCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		builder := gorocket.NewBuilder(".gorocket.yml")
 		return builder.Build(gorocket.BuildParams{
-			Clean:      flagBuildClean,
-			AllowDirty: flagBuildAllowDirty,
+			Clean:       flagBuildClean,
+			AllowDirty:  flagBuildAllowDirty,
+			Parallelism: flagBuildParallelism,
+			Targets:     flagBuildTargets,
+
+			VerifyReproducible: flagBuildVerifyReproducible,
 		})
 	},
 }
@@ -25,5 +33,8 @@ var buildCmd = &cobra.Command{
 func init() {
 	buildCmd.Flags().BoolVar(&flagBuildClean, "clean", false, "Remove dist directory before building")
 	buildCmd.Flags().BoolVar(&flagBuildAllowDirty, "allow-dirty", false, "Allow building without git tag (uses v0.0.0-dev as version)")
+	buildCmd.Flags().IntVar(&flagBuildParallelism, "parallelism", 0, "Number of targets to build concurrently (defaults to build.parallelism or the number of CPUs)")
+	buildCmd.Flags().StringArrayVar(&flagBuildTargets, "target", nil, "Only build targets matching the OS/arch glob pattern (e.g. linux/amd64, darwin/*); can be repeated")
+	buildCmd.Flags().BoolVar(&flagBuildVerifyReproducible, "verify-reproducible", false, "Build twice into separate directories and verify that the artifacts are identical")
 	rootCmd.AddCommand(buildCmd)
 }
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/koki-develop/gorocket/internal/gorocket"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagChangelogGitHubToken string // --github-token
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Preview the changelog of the next release",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token := flagChangelogGitHubToken
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+
+		changelog, err := gorocket.NewChangelogger(".gorocket.yml", token, flagVerbose).Generate()
+		if err != nil {
+			return err
+		}
+		fmt.Print(changelog)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.Flags().StringVar(&flagChangelogGitHubToken, "github-token", "", "GitHub token used to resolve author handles (defaults to GITHUB_TOKEN env var)")
+}
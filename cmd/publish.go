@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/koki-develop/gorocket/internal/gorocket"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagPublishDraft        bool   // --draft
+	flagPublishFailIfExists bool   // --fail-if-exists
+	flagPublishReleaseNotes string // --release-notes
+	flagPublishGitHubToken  string // --github-token
+	flagPublishGitLabToken  string // --gitlab-token
+	flagPublishGiteaToken   string // --gitea-token
+
+	flagPublishUploadParallelism int // --upload-parallelism
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Create a release on GitHub, GitLab or Gitea from the artifacts in the dist directory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		releaser, err := gorocket.NewReleaser(".gorocket.yml", gorocket.Tokens{
+			GitHub: flagPublishGitHubToken,
+			GitLab: flagPublishGitLabToken,
+			Gitea:  flagPublishGiteaToken,
+		}, flagVerbose)
+		if err != nil {
+			return err
+		}
+		return releaser.Publish(gorocket.PublishParams{
+			Draft:             flagPublishDraft,
+			FailIfExists:      flagPublishFailIfExists,
+			ReleaseNotes:      flagPublishReleaseNotes,
+			UploadParallelism: flagPublishUploadParallelism,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+	publishCmd.Flags().StringVar(&flagPublishGitHubToken, "github-token", "", "GitHub token (defaults to GITHUB_TOKEN env var)")
+	publishCmd.Flags().StringVar(&flagPublishGitLabToken, "gitlab-token", "", "GitLab token (defaults to GITLAB_TOKEN env var)")
+	publishCmd.Flags().StringVar(&flagPublishGiteaToken, "gitea-token", "", "Gitea or Forgejo token (defaults to GITEA_TOKEN env var)")
+	publishCmd.Flags().BoolVar(&flagPublishDraft, "draft", false, "Create a draft release")
+	publishCmd.Flags().BoolVar(&flagPublishFailIfExists, "fail-if-exists", false, "Fail if the release already exists")
+	publishCmd.Flags().StringVar(&flagPublishReleaseNotes, "release-notes", "", "Read release notes from a file instead of generating a changelog")
+	publishCmd.Flags().IntVar(&flagPublishUploadParallelism, "upload-parallelism", 0, "Number of assets to upload concurrently (defaults to release.upload_parallelism or 4)")
+}
@@ -6,23 +6,37 @@ import (
 )
 
 var (
-	flagReleaseDraft       bool   // --draft
-	flagReleaseGitHubToken string // --github-token
+	flagReleaseDraft        bool   // --draft
+	flagReleaseFailIfExists bool   // --fail-if-exists
+	flagReleaseReleaseNotes string // --release-notes
+	flagReleaseGitHubToken  string // --github-token
+	flagReleaseGitLabToken  string // --gitlab-token
+	flagReleaseGiteaToken   string // --gitea-token
 
-	flagReleaseClean bool // --clean
+	flagReleaseClean             bool // --clean
+	flagReleaseParallelism       int  // --parallelism
+	flagReleaseUploadParallelism int  // --upload-parallelism
 )
 
 var releaseCmd = &cobra.Command{
 	Use:   "release",
-	Short: "Create a GitHub release with built artifacts",
+	Short: "Create a release on GitHub, GitLab or Gitea with built artifacts",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		releaser, err := gorocket.NewReleaser(".gorocket.yml", flagReleaseGitHubToken)
+		releaser, err := gorocket.NewReleaser(".gorocket.yml", gorocket.Tokens{
+			GitHub: flagReleaseGitHubToken,
+			GitLab: flagReleaseGitLabToken,
+			Gitea:  flagReleaseGiteaToken,
+		}, flagVerbose)
 		if err != nil {
 			return err
 		}
 		return releaser.Release(gorocket.ReleaseParams{
-			Draft: flagReleaseDraft,
-			Clean: flagReleaseClean,
+			Draft:             flagReleaseDraft,
+			FailIfExists:      flagReleaseFailIfExists,
+			ReleaseNotes:      flagReleaseReleaseNotes,
+			Clean:             flagReleaseClean,
+			Parallelism:       flagReleaseParallelism,
+			UploadParallelism: flagReleaseUploadParallelism,
 		})
 	},
 }
@@ -30,6 +44,12 @@ var releaseCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(releaseCmd)
 	releaseCmd.Flags().StringVar(&flagReleaseGitHubToken, "github-token", "", "GitHub token (defaults to GITHUB_TOKEN env var)")
+	releaseCmd.Flags().StringVar(&flagReleaseGitLabToken, "gitlab-token", "", "GitLab token (defaults to GITLAB_TOKEN env var)")
+	releaseCmd.Flags().StringVar(&flagReleaseGiteaToken, "gitea-token", "", "Gitea or Forgejo token (defaults to GITEA_TOKEN env var)")
 	releaseCmd.Flags().BoolVar(&flagReleaseDraft, "draft", false, "Create a draft release")
+	releaseCmd.Flags().BoolVar(&flagReleaseFailIfExists, "fail-if-exists", false, "Fail if the release already exists")
+	releaseCmd.Flags().StringVar(&flagReleaseReleaseNotes, "release-notes", "", "Read release notes from a file instead of generating a changelog")
 	releaseCmd.Flags().BoolVar(&flagReleaseClean, "clean", false, "Remove dist directory before building")
+	releaseCmd.Flags().IntVar(&flagReleaseParallelism, "parallelism", 0, "Number of targets to build concurrently (defaults to build.parallelism or the number of CPUs)")
+	releaseCmd.Flags().IntVar(&flagReleaseUploadParallelism, "upload-parallelism", 0, "Number of assets to upload concurrently (defaults to release.upload_parallelism or 4)")
 }
@@ -6,11 +6,19 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	flagVerbose bool // --verbose
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "gorocket",
 	Short: "Cross-platform Go binary builder",
 }
 
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&flagVerbose, "verbose", false, "Print detailed output such as the GitHub API rate limit")
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/koki-develop/gorocket/internal/gorocket"
+	"github.com/spf13/cobra"
+)
+
+var targetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Print the resolved build matrix",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		builder := gorocket.NewBuilder(".gorocket.yml")
+		return builder.ListTargets(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(targetsCmd)
+}
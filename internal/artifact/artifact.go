@@ -0,0 +1,206 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koki-develop/gorocket/internal/util"
+)
+
+// ArtifactsFile is the name of the artifact manifest in the dist directory
+const ArtifactsFile = "artifacts.json"
+
+// MetadataFile is the name of the release metadata file in the dist directory
+const MetadataFile = "metadata.json"
+
+// Type represents the kind of an artifact
+type Type string
+
+const (
+	// TypeArchive is an archive (or bare binary) built for a target
+	TypeArchive Type = "Archive"
+	// TypeChecksum is a checksums file or a checksum sidecar file
+	TypeChecksum Type = "Checksum"
+	// TypeFormula is a Homebrew Formula
+	TypeFormula Type = "Brew Formula"
+)
+
+// Artifact represents a file produced by the pipeline
+type Artifact struct {
+	Type     Type           `json:"type"`
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	OS       string         `json:"os,omitempty"`
+	Arch     string         `json:"arch,omitempty"`
+	Variant  string         `json:"variant,omitempty"`
+	Checksum string         `json:"checksum"`
+	Size     int64          `json:"size"`
+	Binaries []Binary       `json:"binaries,omitempty"`
+	Extra    map[string]any `json:"extra,omitempty"`
+}
+
+// Binary represents a binary contained in an artifact
+type Binary struct {
+	BuildID string `json:"build_id"`
+	Name    string `json:"name"`
+}
+
+// SHA256 returns the SHA256 digest of the artifact without the algorithm prefix
+func (a *Artifact) SHA256() string {
+	return strings.TrimPrefix(a.Checksum, "sha256:")
+}
+
+// Metadata describes the release the artifacts were built for
+type Metadata struct {
+	ProjectName string    `json:"project_name"`
+	Module      string    `json:"module"`
+	Tag         string    `json:"tag"`
+	Version     string    `json:"version"`
+	PreviousTag string    `json:"previous_tag,omitempty"`
+	Commit      string    `json:"commit"`
+	ShortCommit string    `json:"short_commit"`
+	CommitDate  time.Time `json:"commit_date"`
+	Date        time.Time `json:"date"`
+	IsSnapshot  bool      `json:"is_snapshot"`
+}
+
+// Filter selects artifacts
+type Filter func(a *Artifact) bool
+
+// ByType selects artifacts of any of the given types
+func ByType(types ...Type) Filter {
+	return func(a *Artifact) bool { return slices.Contains(types, a.Type) }
+}
+
+// Registry holds the artifacts produced by the pipeline. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.Mutex
+	artifacts []*Artifact
+}
+
+// New creates a new empty Registry
+func New() *Registry {
+	return &Registry{}
+}
+
+// Add registers a file as an artifact, filling in its name, size and checksum.
+// Names are unique since artifacts are uploaded by name.
+func (r *Registry) Add(a *Artifact) error {
+	file, err := os.Open(a.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", a.Path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+	sum, err := util.CalculateSHA256(file)
+	if err != nil {
+		return fmt.Errorf("failed to calculate SHA256 for %s: %w", a.Path, err)
+	}
+
+	if a.Name == "" {
+		a.Name = filepath.Base(a.Path)
+	}
+	a.Size = info.Size()
+	a.Checksum = "sha256:" + sum
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.ContainsFunc(r.artifacts, func(other *Artifact) bool { return other.Name == a.Name }) {
+		return fmt.Errorf("duplicate artifact name: %s", a.Name)
+	}
+	r.artifacts = append(r.artifacts, a)
+	return nil
+}
+
+// List returns the artifacts matching every filter in the order they were added
+func (r *Registry) List(filters ...Filter) []*Artifact {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var artifacts []*Artifact
+	for _, a := range r.artifacts {
+		if !slices.ContainsFunc(filters, func(f Filter) bool { return !f(a) }) {
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts
+}
+
+// Write saves the artifacts and metadata to the dist directory
+func (r *Registry) Write(distDir string, metadata *Metadata) error {
+	artifacts := r.List()
+	if artifacts == nil {
+		artifacts = []*Artifact{}
+	}
+	if err := writeJSON(filepath.Join(distDir, ArtifactsFile), artifacts); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(distDir, MetadataFile), metadata)
+}
+
+// Load reads the artifacts and metadata written by Write
+func Load(distDir string) (*Registry, *Metadata, error) {
+	var artifacts []*Artifact
+	if err := readJSON(filepath.Join(distDir, ArtifactsFile), &artifacts); err != nil {
+		return nil, nil, err
+	}
+	var metadata Metadata
+	if err := readJSON(filepath.Join(distDir, MetadataFile), &metadata); err != nil {
+		return nil, nil, err
+	}
+
+	return &Registry{artifacts: artifacts}, &metadata, nil
+}
+
+// Verify checks that the files of the artifacts haven't changed since they were registered
+func (r *Registry) Verify() error {
+	for _, a := range r.List() {
+		file, err := os.Open(a.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open file %s: %w", a.Path, err)
+		}
+		sum, err := util.CalculateSHA256(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to calculate SHA256 for %s: %w", a.Path, err)
+		}
+		if "sha256:"+sum != a.Checksum {
+			return fmt.Errorf("%s has changed since it was built", a.Path)
+		}
+	}
+	return nil
+}
+
+// writeJSON writes v to path as indented JSON
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// readJSON reads JSON from path into v
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
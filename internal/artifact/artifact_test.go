@@ -0,0 +1,48 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Registry(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "app_linux_amd64.tar.gz")
+	checksumsPath := filepath.Join(dir, "checksums.txt")
+	require.NoError(t, os.WriteFile(archivePath, []byte("hello world"), 0644))
+	require.NoError(t, os.WriteFile(checksumsPath, []byte(""), 0644))
+
+	r := New()
+	require.NoError(t, r.Add(&Artifact{Type: TypeArchive, Path: archivePath, OS: "linux", Arch: "amd64"}))
+	require.NoError(t, r.Add(&Artifact{Type: TypeChecksum, Path: checksumsPath}))
+	assert.Error(t, r.Add(&Artifact{Type: TypeArchive, Path: filepath.Join(dir, "missing")}))
+	assert.EqualError(t, r.Add(&Artifact{Type: TypeArchive, Path: archivePath, OS: "linux", Arch: "arm64"}), "duplicate artifact name: app_linux_amd64.tar.gz")
+
+	archives := r.List(ByType(TypeArchive))
+	require.Len(t, archives, 1)
+	assert.Equal(t, "app_linux_amd64.tar.gz", archives[0].Name)
+	assert.Equal(t, int64(11), archives[0].Size)
+	assert.Equal(t, "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", archives[0].Checksum)
+	assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", archives[0].SHA256())
+
+	assert.Len(t, r.List(), 2)
+	assert.Len(t, r.List(ByType(TypeArchive, TypeChecksum)), 2)
+	assert.Empty(t, r.List(ByType(TypeFormula)))
+
+	require.NoError(t, r.Write(dir, &Metadata{ProjectName: "app", Tag: "v1.0.0"}))
+	assert.FileExists(t, filepath.Join(dir, ArtifactsFile))
+	assert.FileExists(t, filepath.Join(dir, MetadataFile))
+
+	loaded, metadata, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", metadata.Tag)
+	assert.Equal(t, r.List(), loaded.List())
+	assert.NoError(t, loaded.Verify())
+
+	require.NoError(t, os.WriteFile(archivePath, []byte("changed"), 0644))
+	assert.Error(t, loaded.Verify())
+}
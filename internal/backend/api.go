@@ -0,0 +1,158 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIClient sends requests to the REST API of a backend, such as GitLab or Gitea
+type APIClient struct {
+	URL        string // Base URL of the API, ending with a slash
+	name       string
+	authHeader string
+	auth       string
+	client     *http.Client
+}
+
+// APIOptions configures an APIClient
+type APIOptions struct {
+	Name          string // Name of the backend in errors, e.g. GitLab
+	URL           string // Base URL of the API
+	AuthHeader    string // Header carrying the credentials, e.g. Authorization
+	Auth          string // Value of the auth header, e.g. token xxx
+	SkipTLSVerify bool   // Don't verify TLS certificates, e.g. of self-signed instances
+}
+
+// APIError is an error response of a backend API
+type APIError struct {
+	API        string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s API returned %d", e.API, e.StatusCode)
+	}
+	return fmt.Sprintf("%s API returned %d: %s", e.API, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// NewAPIClient creates a new APIClient
+func NewAPIClient(options APIOptions) (*APIClient, error) {
+	if u, err := url.Parse(options.URL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s API URL: %s", options.Name, options.URL)
+	}
+	apiURL := options.URL
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.SkipTLSVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &APIClient{
+		URL:        apiURL,
+		name:       options.Name,
+		authHeader: options.AuthHeader,
+		auth:       options.Auth,
+		client:     &http.Client{Transport: transport},
+	}, nil
+}
+
+// Do sends a JSON request to the API and decodes the response into v unless v is nil
+func (c *APIClient) Do(method, path string, body any, v any) error {
+	_, err := c.DoResponse(method, path, body, v)
+	return err
+}
+
+// DoResponse is Do returning the response, whose body is already consumed
+func (c *APIClient) DoResponse(method, path string, body any, v any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if v == nil {
+		return c.Send(req, io.Discard)
+	}
+	var buf bytes.Buffer
+	resp, err := c.Send(req, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return resp, json.Unmarshal(buf.Bytes(), v)
+}
+
+// Send sends an authenticated request, copies the response body to w and returns the response
+func (c *APIClient) Send(req *http.Request, w io.Writer) (*http.Response, error) {
+	req.Header.Set(c.authHeader, c.auth)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, c.responseError(resp)
+	}
+	if w != nil {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// responseError converts an error response
+func (c *APIClient) responseError(resp *http.Response) error {
+	apiErr := &APIError{API: c.name, StatusCode: resp.StatusCode}
+
+	var body struct {
+		Message any    `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil {
+		switch {
+		case body.Message != nil:
+			// The message is either a string or an object of field errors
+			if message, ok := body.Message.(string); ok {
+				apiErr.Message = message
+			} else if b, err := json.Marshal(body.Message); err == nil {
+				apiErr.Message = string(b)
+			}
+		case body.Error != "":
+			apiErr.Message = body.Error
+		}
+	}
+
+	return apiErr
+}
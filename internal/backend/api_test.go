@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/backend/backendtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewAPIClient(t *testing.T) {
+	c, err := NewAPIClient(APIOptions{Name: "GitLab", URL: "https://gitlab.example.com/api/v4"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.example.com/api/v4/", c.URL)
+
+	_, err = NewAPIClient(APIOptions{Name: "GitLab", URL: "gitlab.example.com"})
+	assert.EqualError(t, err, "invalid GitLab API URL: gitlab.example.com")
+}
+
+func Test_APIClient_Do(t *testing.T) {
+	var s *backendtest.Server
+	s = backendtest.NewServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token token" {
+			s.Reply(w, http.StatusUnauthorized, map[string]any{"message": "token is required"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/items":
+			var body map[string]any
+			if !s.Decode(w, r, &body) {
+				return
+			}
+			assert.Equal(s.T, "application/json", r.Header.Get("Content-Type"))
+			w.Header().Set("X-Next-Page", "2")
+			s.Reply(w, http.StatusCreated, body)
+		case "/api/fields":
+			s.Reply(w, http.StatusBadRequest, map[string]any{"message": map[string]any{"name": []string{"has already been taken"}}})
+		case "/api/error":
+			s.Reply(w, http.StatusForbidden, map[string]any{"error": "insufficient_scope"})
+		case "/api/missing":
+			s.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not Found"})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	c, err := NewAPIClient(APIOptions{Name: "Gitea", URL: s.URL + "/api", AuthHeader: "Authorization", Auth: "token token"})
+	require.NoError(t, err)
+
+	var got map[string]any
+	resp, err := c.DoResponse(http.MethodPost, "items", map[string]any{"name": "app"}, &got)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "app"}, got)
+	assert.Equal(t, "2", resp.Header.Get("X-Next-Page"))
+
+	tests := []struct {
+		path     string
+		expected string
+		notFound bool
+	}{
+		{path: "fields", expected: `Gitea API returned 400: {"name":["has already been taken"]}`},
+		{path: "error", expected: "Gitea API returned 403: insufficient_scope"},
+		{path: "missing", expected: "Gitea API returned 404: 404 Not Found", notFound: true},
+		{path: "empty", expected: "Gitea API returned 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := c.Do(http.MethodGet, tt.path, nil, nil)
+			assert.EqualError(t, err, tt.expected)
+			assert.Equal(t, tt.notFound, IsNotFound(err))
+		})
+	}
+
+	c, err = NewAPIClient(APIOptions{Name: "Gitea", URL: s.URL + "/api", AuthHeader: "Authorization", Auth: "token invalid"})
+	require.NoError(t, err)
+	assert.EqualError(t, c.Do(http.MethodGet, "items", nil, nil), "Gitea API returned 401: token is required")
+}
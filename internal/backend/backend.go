@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"io"
+)
+
+// Backend is a service that hosts releases and the Homebrew tap repository
+type Backend interface {
+	// GetReleaseByTag retrieves a release by tag name, including drafts. nil is returned if there is no such release.
+	GetReleaseByTag(params GetReleaseByTagParams) (*Release, error)
+	// CreateRelease creates a new release
+	CreateRelease(params CreateReleaseParams) (*Release, error)
+	// UpdateRelease updates the name and body of a release. The name is left as is if empty.
+	UpdateRelease(params UpdateReleaseParams) (*Release, error)
+	// PublishRelease publishes a draft release
+	PublishRelease(params PublishReleaseParams) (*Release, error)
+	// DeleteRelease deletes a release
+	DeleteRelease(params DeleteReleaseParams) error
+
+	// ListReleaseAssets lists every asset of a release
+	ListReleaseAssets(params ListReleaseAssetsParams) ([]*Asset, error)
+	// UploadAsset uploads a file as an asset of a release, aborting once ctx is cancelled
+	UploadAsset(ctx context.Context, params UploadAssetParams) error
+	// DeleteReleaseAsset deletes an asset of a release
+	DeleteReleaseAsset(params DeleteReleaseAssetParams) error
+	// DownloadAsset writes the content of a release asset to w
+	DownloadAsset(params DownloadAssetParams, w io.Writer) error
+
+	// UpdateFile creates or updates a file in a repository
+	UpdateFile(params UpdateFileParams) error
+}
+
+// Release represents a release
+type Release struct {
+	ID      int64 // 0 if the backend identifies releases by tag
+	Tag     string
+	Name    string
+	Body    string
+	Draft   bool
+	Pending bool // Draft that doesn't exist on a backend without drafts until it is published
+}
+
+// Asset represents a file attached to a release
+type Asset struct {
+	ID     int64
+	Name   string
+	Size   int64
+	State  string // uploaded once the content is complete
+	Digest string // e.g. sha256:..., empty if the backend doesn't expose it
+}
+
+// GetReleaseByTagParams represents parameters for GetReleaseByTag
+type GetReleaseByTagParams struct {
+	Owner string
+	Repo  string
+	Tag   string
+}
+
+// CreateReleaseParams represents parameters for CreateRelease
+type CreateReleaseParams struct {
+	Owner      string
+	Repo       string
+	Tag        string
+	Name       string
+	Body       string
+	Draft      bool
+	Prerelease bool
+	MakeLatest string // true, false or legacy
+}
+
+// UpdateReleaseParams represents parameters for UpdateRelease
+type UpdateReleaseParams struct {
+	Owner   string
+	Repo    string
+	Release *Release
+	Name    string
+	Body    string
+}
+
+// PublishReleaseParams represents parameters for PublishRelease
+type PublishReleaseParams struct {
+	Owner      string
+	Repo       string
+	Release    *Release
+	MakeLatest string // true, false or legacy
+	Draft      bool   // Only create a pending release, as a draft as far as the backend supports it
+}
+
+// DeleteReleaseParams represents parameters for DeleteRelease
+type DeleteReleaseParams struct {
+	Owner   string
+	Repo    string
+	Release *Release
+}
+
+// ListReleaseAssetsParams represents parameters for ListReleaseAssets
+type ListReleaseAssetsParams struct {
+	Owner   string
+	Repo    string
+	Release *Release
+}
+
+// UploadAssetParams represents parameters for UploadAsset
+type UploadAssetParams struct {
+	Owner    string
+	Repo     string
+	Release  *Release
+	Name     string
+	Path     string
+	Progress func(written, total int64) // Optional: called as the body is sent, restarting from 0 on retries
+}
+
+// DeleteReleaseAssetParams represents parameters for DeleteReleaseAsset
+type DeleteReleaseAssetParams struct {
+	Owner   string
+	Repo    string
+	Release *Release
+	Asset   *Asset
+}
+
+// DownloadAssetParams represents parameters for DownloadAsset
+type DownloadAssetParams struct {
+	Owner   string
+	Repo    string
+	Release *Release
+	Asset   *Asset
+}
+
+// UpdateFileParams represents parameters for UpdateFile
+type UpdateFileParams struct {
+	Owner         string
+	Repo          string
+	Path          string
+	Content       string
+	CommitMessage string
+}
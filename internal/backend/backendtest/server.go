@@ -0,0 +1,62 @@
+// Package backendtest provides a fake API server for testing backend clients
+package backendtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Server is a fake API server handling one request at a time
+type Server struct {
+	*httptest.Server
+	T  *testing.T
+	mu sync.Mutex
+}
+
+// NewServer starts a fake API server passing requests to handle, which is closed when the test ends.
+// handle runs off the test goroutine, so it must report failures with assert instead of require.
+func NewServer(t *testing.T, handle http.HandlerFunc) *Server {
+	t.Helper()
+
+	s := &Server{T: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		handle(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Reply writes a JSON response
+func (s *Server) Reply(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	assert.NoError(s.T, json.NewEncoder(w).Encode(v))
+}
+
+// Decode decodes a JSON request body and replies 400 if it is invalid
+func (s *Server) Decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	return s.Check(w, json.NewDecoder(r.Body).Decode(v))
+}
+
+// Check fails the test and replies 400 if err is not nil
+func (s *Server) Check(w http.ResponseWriter, err error) bool {
+	if !assert.NoError(s.T, err) {
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Unexpected fails the test on a request the server doesn't handle
+func (s *Server) Unexpected(w http.ResponseWriter, r *http.Request) {
+	s.T.Errorf("unexpected request: %s %s", r.Method, r.URL.EscapedPath())
+	w.WriteHeader(http.StatusNotImplemented)
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// mediaTypes maps the extensions of release artifacts to media types,
+// since the system MIME database doesn't reliably know them
+var mediaTypes = map[string]string{
+	".gz":  "application/gzip",
+	".tgz": "application/gzip",
+	".xz":  "application/x-xz",
+	".zst": "application/zstd",
+	".tar": "application/x-tar",
+	".zip": "application/zip",
+	".txt": "text/plain; charset=utf-8",
+}
+
+// MediaType returns the media type of an asset derived from its file extension
+func MediaType(name string) string {
+	if mediaType, ok := mediaTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return mediaType
+	}
+	if mediaType := mime.TypeByExtension(filepath.Ext(name)); mediaType != "" {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// ProgressReader reports the number of bytes read so far
+type ProgressReader struct {
+	r        io.Reader
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+// NewProgressReader creates a reader that calls progress after every read from r
+func NewProgressReader(r io.Reader, total int64, progress func(written, total int64)) *ProgressReader {
+	progress(0, total)
+	return &ProgressReader{r: r, total: total, progress: progress}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.progress(p.written, p.total)
+	}
+	return n, err
+}
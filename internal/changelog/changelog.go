@@ -0,0 +1,144 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// conventionalPattern matches Conventional Commits subjects (e.g. "feat(cli)!: add flag")
+var conventionalPattern = regexp.MustCompile(`^(\w+)(?:\([^)]*\))?!?:\s`)
+
+// group represents a changelog section and the commit types it contains
+type group struct {
+	Title string
+	Types []string
+}
+
+// groups lists the changelog sections in order. The last one contains every other commit.
+var groups = []group{
+	{Title: "Features", Types: []string{"feat"}},
+	{Title: "Bug Fixes", Types: []string{"fix"}},
+	{Title: "Others", Types: nil},
+}
+
+// Commit represents a commit included in the changelog
+type Commit struct {
+	ShortHash string
+	Subject   string
+	URL       string // Optional: link to the commit
+	Author    string // Optional: e.g. @octocat
+}
+
+// Options controls how the changelog is generated
+type Options struct {
+	Sort    string   // asc or desc by subject, or empty to keep the git order
+	Include []string // Regular expressions; only matching commits are included if set
+	Exclude []string // Regular expressions; matching commits are excluded
+	Group   bool     // Group commits by Conventional Commits type
+}
+
+// Generate renders a Markdown changelog. An empty string is returned if no commit remains after filtering.
+func Generate(commits []Commit, opts Options) (string, error) {
+	include, err := compile(opts.Include)
+	if err != nil {
+		return "", fmt.Errorf("invalid changelog include filter: %w", err)
+	}
+	exclude, err := compile(opts.Exclude)
+	if err != nil {
+		return "", fmt.Errorf("invalid changelog exclude filter: %w", err)
+	}
+
+	// Filter commits
+	var filtered []Commit
+	for _, commit := range commits {
+		if len(include) > 0 && !matchAny(include, commit.Subject) {
+			continue
+		}
+		if matchAny(exclude, commit.Subject) {
+			continue
+		}
+		filtered = append(filtered, commit)
+	}
+	if len(filtered) == 0 {
+		return "", nil
+	}
+
+	// Sort commits
+	switch opts.Sort {
+	case "":
+	case "asc":
+		slices.SortStableFunc(filtered, func(a, b Commit) int { return strings.Compare(a.Subject, b.Subject) })
+	case "desc":
+		slices.SortStableFunc(filtered, func(a, b Commit) int { return strings.Compare(b.Subject, a.Subject) })
+	default:
+		return "", fmt.Errorf("invalid changelog.sort: %s (expected asc or desc)", opts.Sort)
+	}
+
+	var b strings.Builder
+	b.WriteString("## Changelog\n")
+
+	if !opts.Group {
+		b.WriteString("\n")
+		for _, commit := range filtered {
+			b.WriteString(line(commit))
+		}
+		return b.String(), nil
+	}
+
+	// Group commits by type, falling back to the last group
+	sections := make([][]Commit, len(groups))
+	for _, commit := range filtered {
+		i := len(groups) - 1
+		if m := conventionalPattern.FindStringSubmatch(commit.Subject); m != nil {
+			if j := slices.IndexFunc(groups, func(g group) bool { return slices.Contains(g.Types, strings.ToLower(m[1])) }); j >= 0 {
+				i = j
+			}
+		}
+		sections[i] = append(sections[i], commit)
+	}
+
+	for i, section := range sections {
+		if len(section) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", groups[i].Title)
+		for _, commit := range section {
+			b.WriteString(line(commit))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// line renders a single changelog entry
+func line(commit Commit) string {
+	hash := commit.ShortHash
+	if commit.URL != "" {
+		hash = fmt.Sprintf("[%s](%s)", commit.ShortHash, commit.URL)
+	}
+
+	if commit.Author != "" {
+		return fmt.Sprintf("- %s %s (%s)\n", hash, commit.Subject, commit.Author)
+	}
+	return fmt.Sprintf("- %s %s\n", hash, commit.Subject)
+}
+
+// compile compiles regular expressions
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	var regexps []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// matchAny reports whether s matches any of the regular expressions
+func matchAny(regexps []*regexp.Regexp, s string) bool {
+	return slices.ContainsFunc(regexps, func(re *regexp.Regexp) bool { return re.MatchString(s) })
+}
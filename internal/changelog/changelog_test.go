@@ -0,0 +1,79 @@
+package changelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Generate(t *testing.T) {
+	commits := []Commit{
+		{ShortHash: "aaaaaaa", Subject: "fix: handle empty input", Author: "@alice"},
+		{ShortHash: "bbbbbbb", Subject: "docs: update README"},
+		{ShortHash: "ccccccc", Subject: "feat(cli)!: add --verbose", URL: "https://github.com/o/r/commit/ccccccc"},
+		{ShortHash: "ddddddd", Subject: "Bump dependencies", Author: "Bob"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "grouped",
+			opts: Options{Group: true},
+			expected: "## Changelog\n" +
+				"\n### Features\n\n" +
+				"- [ccccccc](https://github.com/o/r/commit/ccccccc) feat(cli)!: add --verbose\n" +
+				"\n### Bug Fixes\n\n" +
+				"- aaaaaaa fix: handle empty input (@alice)\n" +
+				"\n### Others\n\n" +
+				"- bbbbbbb docs: update README\n" +
+				"- ddddddd Bump dependencies (Bob)\n",
+		},
+		{
+			name: "flat sorted with filters",
+			opts: Options{Sort: "desc", Exclude: []string{"^docs:"}},
+			expected: "## Changelog\n\n" +
+				"- aaaaaaa fix: handle empty input (@alice)\n" +
+				"- [ccccccc](https://github.com/o/r/commit/ccccccc) feat(cli)!: add --verbose\n" +
+				"- ddddddd Bump dependencies (Bob)\n",
+		},
+		{
+			name: "include",
+			opts: Options{Sort: "asc", Include: []string{"^(feat|fix)"}},
+			expected: "## Changelog\n\n" +
+				"- [ccccccc](https://github.com/o/r/commit/ccccccc) feat(cli)!: add --verbose\n" +
+				"- aaaaaaa fix: handle empty input (@alice)\n",
+		},
+		{
+			name:     "nothing left",
+			opts:     Options{Exclude: []string{"."}},
+			expected: "",
+		},
+		{
+			name:    "invalid sort",
+			opts:    Options{Sort: "random"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid filter",
+			opts:    Options{Exclude: []string{"("}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Generate(commits, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"text/template"
+	"path"
+	"reflect"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,25 +15,315 @@ import (
 // Config defines the configuration file structure
 type Config struct {
 	Build struct {
-		Targets []Target `yaml:"targets"`
-		Ldflags string   `yaml:"ldflags"`
+		BuildOptions `yaml:",inline"`
+		Binary       Template `yaml:"binary"`
+		Targets      []Target `yaml:"targets"`
+		Ignore       []Ignore `yaml:"ignore"`
+		Parallelism  int      `yaml:"parallelism"`
 	} `yaml:"build"`
 
+	Builds []Build `yaml:"builds"`
+
+	Archives Archives `yaml:"archives"`
+
+	Checksum struct {
+		Algorithm string `yaml:"algorithm"`
+		Sidecar   bool   `yaml:"sidecar"`
+		Disable   bool   `yaml:"disable"`
+	} `yaml:"checksum"`
+
+	Changelog Changelog `yaml:"changelog"`
+
+	Release struct {
+		Backend           string   `yaml:"backend"` // github, gitlab or gitea (detected from the remote by default)
+		Mode              string   `yaml:"mode"`    // keep-existing (default), append or replace
+		NameTemplate      Template `yaml:"name_template"`
+		Header            Template `yaml:"header"`
+		Footer            Template `yaml:"footer"`
+		Prerelease        string   `yaml:"prerelease"`  // auto (default), true or false
+		MakeLatest        string   `yaml:"make_latest"` // auto (default), true, false or legacy
+		OnFailure         string   `yaml:"on_failure"`  // keep (default) or delete the draft release
+		UploadParallelism int      `yaml:"upload_parallelism"`
+		VerifyDownloads   bool     `yaml:"verify_downloads"` // Download assets again after uploading to verify their checksums
+	} `yaml:"release"`
+
+	GitHub struct {
+		Retry struct {
+			Attempts        int           `yaml:"attempts"`
+			InitialInterval time.Duration `yaml:"initial_interval"`
+			MaxInterval     time.Duration `yaml:"max_interval"`
+			MaxWait         time.Duration `yaml:"max_wait"`
+		} `yaml:"retry"`
+	} `yaml:"github"`
+
+	GitHubURLs struct {
+		API           string `yaml:"api"`      // e.g. https://github.example.com/api/v3/
+		Upload        string `yaml:"upload"`   // e.g. https://github.example.com/api/uploads/
+		Download      string `yaml:"download"` // e.g. https://github.example.com
+		SkipTLSVerify bool   `yaml:"skip_tls_verify"`
+	} `yaml:"github_urls"`
+
+	GitLabURLs struct {
+		API           string `yaml:"api"`      // e.g. https://gitlab.example.com/api/v4/
+		Download      string `yaml:"download"` // e.g. https://gitlab.example.com
+		SkipTLSVerify bool   `yaml:"skip_tls_verify"`
+	} `yaml:"gitlab_urls"`
+
+	GiteaURLs struct {
+		API           string `yaml:"api"`      // e.g. https://gitea.example.com/api/v1/
+		Download      string `yaml:"download"` // e.g. https://gitea.example.com
+		SkipTLSVerify bool   `yaml:"skip_tls_verify"`
+	} `yaml:"gitea_urls"`
+
 	Brew struct {
 		Repository struct {
 			Owner string `yaml:"owner"`
 			Name  string `yaml:"name"`
 		} `yaml:"repository"`
+		Builds          []string `yaml:"builds"`
+		AllowPrerelease bool     `yaml:"allow_prerelease"` // Update the tap for prereleases too
 	} `yaml:"brew"`
 }
 
+// Changelog represents changelog settings
+type Changelog struct {
+	Disable bool   `yaml:"disable"`
+	Sort    string `yaml:"sort"` // asc or desc by message (defaults to the git log order)
+	Filters struct {
+		Include []string `yaml:"include"`
+		Exclude []string `yaml:"exclude"`
+	} `yaml:"filters"`
+	Group   string `yaml:"group"`   // conventional (default) or none
+	Links   *bool  `yaml:"links"`   // Link commits (defaults to true)
+	Authors *bool  `yaml:"authors"` // Show commit authors (defaults to true)
+}
+
+// Build represents a binary to build
+type Build struct {
+	BuildOptions `yaml:",inline"`
+	ID           string   `yaml:"id"`
+	Main         string   `yaml:"main"`
+	Binary       Template `yaml:"binary"`
+	Targets      []Target `yaml:"targets"`
+	Ignore       []Ignore `yaml:"ignore"`
+}
+
+// Archives represents archive settings
+type Archives struct {
+	Bundle          bool             `yaml:"bundle"`
+	NameTemplate    Template         `yaml:"name_template"`
+	WrapInDirectory WrapInDirectory  `yaml:"wrap_in_directory"`
+	Format          string           `yaml:"format"`
+	FormatOverrides []FormatOverride `yaml:"format_overrides"`
+	Files           []ArchiveFile    `yaml:"files"`
+	MissingFiles    string           `yaml:"missing_files"` // error (default) or warn
+}
+
+// WrapInDirectory controls the top-level directory of archives.
+// It can be written as a boolean or as a template for the directory name.
+type WrapInDirectory struct {
+	Disabled bool
+	Name     Template
+}
+
+// UnmarshalYAML allows wrap_in_directory to be a boolean or a template
+func (w *WrapInDirectory) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("wrap_in_directory must be a boolean or a string")
+	}
+
+	var enabled bool
+	if value.Tag == "!!bool" {
+		if err := value.Decode(&enabled); err != nil {
+			return err
+		}
+		w.Disabled = !enabled
+		return nil
+	}
+
+	w.Name = Template(value.Value)
+	return nil
+}
+
+// FormatOverride represents an archive format used for a specific OS
+type FormatOverride struct {
+	OS     string `yaml:"os"`
+	Format string `yaml:"format"`
+}
+
+// FormatFor returns the archive format for the given OS.
+// Without any configuration, Windows uses zip and other OSes use tar.gz.
+func (a Archives) FormatFor(goos string) string {
+	for _, override := range a.FormatOverrides {
+		if override.OS == goos {
+			return override.Format
+		}
+	}
+
+	if a.Format != "" {
+		return a.Format
+	}
+	if goos == "windows" {
+		return "zip"
+	}
+	return "tar.gz"
+}
+
+// ArchiveFile represents extra files to include in archives.
+// It can be written as a plain glob or as a mapping.
+type ArchiveFile struct {
+	Src         string `yaml:"src"`
+	Dst         string `yaml:"dst"`
+	Mode        uint32 `yaml:"mode"`
+	StripParent bool   `yaml:"strip_parent"`
+}
+
+// UnmarshalYAML allows an archive file to be written as a plain glob
+func (f *ArchiveFile) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.Src = value.Value
+		return nil
+	}
+
+	type plain ArchiveFile
+	return value.Decode((*plain)(f))
+}
+
+// Ignore represents a rule that excludes targets from the build matrix.
+// Each field is a glob pattern, and empty fields match anything.
+type Ignore struct {
+	OS      string `yaml:"os"`
+	Arch    string `yaml:"arch"`
+	Variant string `yaml:"variant"`
+}
+
+// Match reports whether the rule matches the given target
+func (i Ignore) Match(goos, goarch, variant string) (bool, error) {
+	for _, m := range []struct{ pattern, value string }{
+		{i.OS, goos},
+		{i.Arch, goarch},
+		{i.Variant, variant},
+	} {
+		if m.pattern == "" {
+			continue
+		}
+		ok, err := path.Match(m.pattern, m.value)
+		if err != nil {
+			return false, fmt.Errorf("invalid ignore pattern %q: %w", m.pattern, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // Target represents a build target
 type Target struct {
-	OS   string   `yaml:"os"`
-	Arch []string `yaml:"arch"`
+	BuildOptions `yaml:",inline"`
+	OS           string   `yaml:"os"`
+	Arch         []string `yaml:"arch"`
+
+	// Micro-architecture variants
+	Goarm   []string `yaml:"goarm"`
+	Goamd64 []string `yaml:"goamd64"`
+	Goarm64 []string `yaml:"goarm64"`
+	Go386   []string `yaml:"go386"`
+	Gomips  []string `yaml:"gomips"`
+}
+
+// Variants returns the micro-architecture variants configured for arch.
+// An empty variant means the toolchain default.
+func (t Target) Variants(arch string) []string {
+	var variants []string
+	switch arch {
+	case "arm":
+		variants = t.Goarm
+	case "amd64":
+		variants = t.Goamd64
+	case "arm64":
+		variants = t.Goarm64
+	case "386":
+		variants = t.Go386
+	case "mips", "mipsle", "mips64", "mips64le":
+		variants = t.Gomips
+	}
+
+	if len(variants) == 0 {
+		return []string{""}
+	}
+	return variants
+}
+
+// BuildOptions represents options passed to go build.
+// They are rendered for each target, so they can refer to .Os, .Arch and .Variant.
+type BuildOptions struct {
+	Env       []Template `yaml:"env"`
+	Tags      []Template `yaml:"tags"`
+	Ldflags   Template   `yaml:"ldflags"`
+	Gcflags   Template   `yaml:"gcflags"`
+	Asmflags  Template   `yaml:"asmflags"`
+	Trimpath  *bool      `yaml:"trimpath"`
+	Buildmode Template   `yaml:"buildmode"`
+	Flags     []Template `yaml:"flags"`
+}
+
+// Merge returns a copy of the options with the values set in override applied.
+// Environment variables are merged by key, other values are replaced.
+func (o BuildOptions) Merge(override BuildOptions) BuildOptions {
+	merged := o
+
+	if len(override.Env) > 0 {
+		merged.Env = mergeEnv(o.Env, override.Env)
+	}
+	if len(override.Tags) > 0 {
+		merged.Tags = override.Tags
+	}
+	if override.Ldflags != "" {
+		merged.Ldflags = override.Ldflags
+	}
+	if override.Gcflags != "" {
+		merged.Gcflags = override.Gcflags
+	}
+	if override.Asmflags != "" {
+		merged.Asmflags = override.Asmflags
+	}
+	if override.Trimpath != nil {
+		merged.Trimpath = override.Trimpath
+	}
+	if override.Buildmode != "" {
+		merged.Buildmode = override.Buildmode
+	}
+	if len(override.Flags) > 0 {
+		merged.Flags = override.Flags
+	}
+
+	return merged
+}
+
+// mergeEnv merges KEY=VALUE lists, letting entries in override win
+func mergeEnv(base, override []Template) []Template {
+	merged := make([]Template, 0, len(base)+len(override))
+	index := map[string]int{}
+	for _, env := range append(append([]Template{}, base...), override...) {
+		key, _, _ := strings.Cut(string(env), "=")
+		if i, ok := index[key]; ok {
+			merged[i] = env
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, env)
+	}
+	return merged
 }
 
-// LoadConfig loads the configuration file
+// LoadConfig loads the configuration file.
+// If data is provided, string values are rendered as templates with it.
+// Template values are left as is to be rendered when they are used.
+//
+// Configs that only work when rendered as a whole before parsing, like older versions did
+// (templates in keys or at the start of unquoted values, which YAML reads as flow mappings),
+// are still rendered that way. Template values can't refer to the target in such configs.
 func LoadConfig(path string, data map[string]any) (*Config, error) {
 	// Read file
 	content, err := os.ReadFile(path)
@@ -38,25 +331,60 @@ func LoadConfig(path string, data map[string]any) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Process template if data is provided
+	// Parse YAML
+	var config Config
+	err = yaml.Unmarshal(content, &config)
+	if bytes.Contains(content, []byte("{{")) && (err != nil || hasTemplatedKeys(content)) {
+		return loadRenderedConfig(content, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Process templates if data is provided
 	if data != nil {
-		tmpl, err := template.New("config").Parse(string(content))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse template: %w", err)
+		if err := render(reflect.ValueOf(&config).Elem(), data, false); err != nil {
+			return nil, err
 		}
+	}
 
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, data); err != nil {
-			return nil, fmt.Errorf("failed to execute template: %w", err)
-		}
-		content = buf.Bytes()
+	return &config, nil
+}
+
+// loadRenderedConfig renders the whole config file as a template before parsing it
+func loadRenderedConfig(content []byte, data map[string]any) (*Config, error) {
+	rendered, err := ExecuteTemplate(string(content), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render config file: %w", err)
 	}
 
-	// Parse YAML
 	var config Config
-	if err := yaml.Unmarshal(content, &config); err != nil {
+	if err := yaml.Unmarshal([]byte(rendered), &config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
 	return &config, nil
 }
+
+// hasTemplatedKeys reports whether any mapping key of the YAML document is a template
+func hasTemplatedKeys(content []byte) bool {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return false
+	}
+
+	var walk func(node *yaml.Node) bool
+	walk = func(node *yaml.Node) bool {
+		for i, child := range node.Content {
+			// Keys and values alternate in mappings
+			if node.Kind == yaml.MappingNode && i%2 == 0 && (child.Kind != yaml.ScalarNode || strings.Contains(child.Value, "{{")) {
+				return true
+			}
+			if walk(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(&root)
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	data := map[string]any{
+		"Version": "v1.2.3",
+		"Module":  "github.com/owner/app",
+		"Env":     map[string]string{"OWNER": "owner"},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, cfg *Config)
+		wantErr bool
+	}{
+		{
+			name: "values are rendered and templates are kept",
+			content: `
+build:
+  ldflags: "-X main.version={{ .Version }}"
+  binary: "app_{{ .Os }}"
+brew:
+  repository:
+    owner: "{{ .Env.OWNER }}"
+`,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, Template("-X main.version={{ .Version }}"), cfg.Build.Ldflags)
+				assert.Equal(t, Template("app_{{ .Os }}"), cfg.Build.Binary)
+				assert.Equal(t, "owner", cfg.Brew.Repository.Owner)
+			},
+		},
+		{
+			// Configs of older versions were rendered as a whole before parsing
+			name: "unquoted templates",
+			content: `
+build:
+  ldflags: -s -w -X main.version={{.Version}}
+  flags:
+    - {{if .Version}}-v{{end}}
+  targets:
+    - os: linux
+      arch: [amd64, arm64]
+brew:
+  repository:
+    owner: {{.Env.OWNER}}
+    name: homebrew-tap
+`,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, Template("-s -w -X main.version=v1.2.3"), cfg.Build.Ldflags)
+				assert.Equal(t, []Template{"-v"}, cfg.Build.Flags)
+				assert.Equal(t, []Target{{OS: "linux", Arch: []string{"amd64", "arm64"}}}, cfg.Build.Targets)
+				assert.Equal(t, "owner", cfg.Brew.Repository.Owner)
+			},
+		},
+		{
+			name: "templated keys",
+			content: `
+brew:
+  repository:
+    {{ "owner" }}: owner
+    name: homebrew-tap
+`,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "owner", cfg.Brew.Repository.Owner)
+			},
+		},
+		{
+			name:    "invalid YAML",
+			content: "build: [",
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			content: "build:\n  ldflags: {{ .Version\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".gorocket.yml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+
+			cfg, err := LoadConfig(path, data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func Test_BuildOptions_Merge(t *testing.T) {
+	enabled, disabled := true, false
+	base := BuildOptions{
+		Env:       []Template{"CGO_ENABLED=0", "GOFLAGS=-mod=readonly"},
+		Tags:      []Template{"netgo"},
+		Ldflags:   "-s -w",
+		Gcflags:   "all=-N",
+		Asmflags:  "all=-trimpath",
+		Trimpath:  &enabled,
+		Buildmode: "exe",
+		Flags:     []Template{"-v"},
+	}
+
+	tests := []struct {
+		name     string
+		override BuildOptions
+		expected BuildOptions
+	}{
+		{
+			name:     "empty override inherits everything",
+			override: BuildOptions{},
+			expected: base,
+		},
+		{
+			name: "every field is overridden",
+			override: BuildOptions{
+				Env:       []Template{"CGO_ENABLED=1"},
+				Tags:      []Template{"osusergo"},
+				Ldflags:   "-X main.version=v1",
+				Gcflags:   "all=-l",
+				Asmflags:  "all=-dynlink",
+				Trimpath:  &disabled,
+				Buildmode: "pie",
+				Flags:     []Template{"-a"},
+			},
+			expected: BuildOptions{
+				Env:       []Template{"CGO_ENABLED=1", "GOFLAGS=-mod=readonly"},
+				Tags:      []Template{"osusergo"},
+				Ldflags:   "-X main.version=v1",
+				Gcflags:   "all=-l",
+				Asmflags:  "all=-dynlink",
+				Trimpath:  &disabled,
+				Buildmode: "pie",
+				Flags:     []Template{"-a"},
+			},
+		},
+		{
+			name:     "disabling trimpath is an override",
+			override: BuildOptions{Trimpath: &disabled},
+			expected: func() BuildOptions {
+				o := base
+				o.Trimpath = &disabled
+				return o
+			}(),
+		},
+		{
+			name:     "lists are replaced instead of appended",
+			override: BuildOptions{Tags: []Template{"osusergo", "netgo"}, Flags: []Template{"-a", "-v"}},
+			expected: func() BuildOptions {
+				o := base
+				o.Tags = []Template{"osusergo", "netgo"}
+				o.Flags = []Template{"-a", "-v"}
+				return o
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, base.Merge(tt.override))
+		})
+	}
+
+	// The base options are left untouched
+	assert.Equal(t, []Template{"CGO_ENABLED=0", "GOFLAGS=-mod=readonly"}, base.Env)
+}
+
+func Test_mergeEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     []Template
+		override []Template
+		expected []Template
+	}{
+		{
+			name:     "new keys are appended",
+			base:     []Template{"CGO_ENABLED=0"},
+			override: []Template{"GOFLAGS=-mod=vendor"},
+			expected: []Template{"CGO_ENABLED=0", "GOFLAGS=-mod=vendor"},
+		},
+		{
+			name:     "existing keys are replaced in place",
+			base:     []Template{"CGO_ENABLED=0", "GOFLAGS=-mod=readonly", "CC=gcc"},
+			override: []Template{"GOFLAGS=-mod=vendor", "CXX=g++"},
+			expected: []Template{"CGO_ENABLED=0", "GOFLAGS=-mod=vendor", "CC=gcc", "CXX=g++"},
+		},
+		{
+			name:     "later entries of the same list win",
+			base:     []Template{"CGO_ENABLED=0", "CGO_ENABLED=1"},
+			expected: []Template{"CGO_ENABLED=1"},
+		},
+		{
+			name:     "values may contain =",
+			base:     []Template{"GOFLAGS=-ldflags=-s"},
+			override: []Template{"GOFLAGS=-ldflags=-w"},
+			expected: []Template{"GOFLAGS=-ldflags=-w"},
+		},
+		{
+			name:     "empty base",
+			override: []Template{"CGO_ENABLED=1"},
+			expected: []Template{"CGO_ENABLED=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mergeEnv(tt.base, tt.override))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/koki-develop/gorocket/internal/util"
+)
+
+// Template is a string that is rendered when it is used rather than when the config file is loaded,
+// so that it can refer to data only known at that point (e.g. the target OS and architecture)
+type Template string
+
+var templateType = reflect.TypeOf(Template(""))
+
+// funcMap defines the functions available in templates, besides time which depends on the data
+var funcMap = template.FuncMap{
+	"replace":    func(s, old, new string) string { return strings.ReplaceAll(s, old, new) },
+	"trimprefix": strings.TrimPrefix,
+	"trimsuffix": strings.TrimSuffix,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"title":      title,
+}
+
+// Execute renders the template with the given data
+func (t Template) Execute(data map[string]any) (string, error) {
+	return ExecuteTemplate(string(t), data)
+}
+
+// ExecuteTemplate renders a template string with the given data
+func ExecuteTemplate(text string, data map[string]any) (string, error) {
+	// Skip strings without actions
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("config").Funcs(funcMap).Funcs(template.FuncMap{"time": formatTime(data)}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// Render renders every Template field of v in place. v must be a pointer.
+func Render(v any, data map[string]any) error {
+	return render(reflect.ValueOf(v).Elem(), data, true)
+}
+
+// render walks v and renders its strings. When deferred is true only Template values are rendered,
+// otherwise only plain strings are.
+func render(v reflect.Value, data map[string]any, deferred bool) error {
+	switch v.Kind() {
+	case reflect.String:
+		if (v.Type() == templateType) != deferred {
+			return nil
+		}
+		rendered, err := ExecuteTemplate(v.String(), data)
+		if err != nil {
+			return err
+		}
+		v.SetString(rendered)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return render(v.Elem(), data, deferred)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := render(v.Field(i), data, deferred); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		// Copy the slice so that renders of copied structs don't share elements
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		for i := range copied.Len() {
+			if err := render(copied.Index(i), data, deferred); err != nil {
+				return err
+			}
+		}
+		v.Set(copied)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			if err := render(value, data, deferred); err != nil {
+				return err
+			}
+			copied.SetMapIndex(key, value)
+		}
+		v.Set(copied)
+	}
+
+	return nil
+}
+
+// title capitalizes the first letter of each word
+func title(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+	return string(runes)
+}
+
+// formatTime returns the time function, which formats the build date of data with the given layout.
+// The date is computed once per build, so every template of a build renders the same time.
+func formatTime(data map[string]any) func(layout string) (string, error) {
+	return func(layout string) (string, error) {
+		// The date is only missing outside of builds
+		value, _ := data["Date"].(string)
+		if value == "" {
+			date, err := util.SourceDate()
+			if err != nil {
+				return "", err
+			}
+			return date.UTC().Format(layout), nil
+		}
+
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return "", fmt.Errorf("invalid build date: %s", value)
+		}
+		return date.UTC().Format(layout), nil
+	}
+}
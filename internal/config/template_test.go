@@ -0,0 +1,121 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExecuteTemplate(t *testing.T) {
+	data := map[string]any{
+		"Version": "v1.2.3",
+		"Env":     map[string]string{"NAME": "gorocket"},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain string",
+			input:    "-s -w",
+			expected: "-s -w",
+		},
+		{
+			name:     "variable",
+			input:    "-X main.version={{ .Version }}",
+			expected: "-X main.version=v1.2.3",
+		},
+		{
+			name:     "env",
+			input:    "{{ .Env.NAME }}",
+			expected: "gorocket",
+		},
+		{
+			name:     "string functions",
+			input:    `{{ trimprefix .Version "v" }} {{ replace .Version "." "_" }} {{ title "hello world" }} {{ upper .Env.NAME }}`,
+			expected: "1.2.3 v1_2_3 Hello World GOROCKET",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteTemplate(tt.input, data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func Test_ExecuteTemplate_time(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	tests := []struct {
+		name     string
+		data     map[string]any
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "build date",
+			data:     map[string]any{"Date": "2024-01-02T03:04:05Z"},
+			expected: "20240102 03:04:05",
+		},
+		{
+			name:     "source date outside of builds",
+			data:     map[string]any{"Date": ""},
+			expected: "20231114 22:13:20",
+		},
+		{
+			name:     "no data",
+			expected: "20231114 22:13:20",
+		},
+		{
+			name:    "invalid date",
+			data:    map[string]any{"Date": "yesterday"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteTemplate(`{{ time "20060102 15:04:05" }}`, tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func Test_Render(t *testing.T) {
+	type target struct {
+		Name    string
+		Ldflags Template
+		Flags   []Template
+	}
+
+	original := target{
+		Name:    "{{ .Os }}",
+		Ldflags: "-X main.os={{ .Os }}",
+		Flags:   []Template{"-tags={{ .Os }}"},
+	}
+
+	// Plain strings are rendered when loading
+	loaded := original
+	assert.NoError(t, render(reflect.ValueOf(&loaded).Elem(), map[string]any{"Os": "loaded"}, false))
+	assert.Equal(t, "loaded", loaded.Name)
+	assert.Equal(t, Template("-X main.os={{ .Os }}"), loaded.Ldflags)
+
+	// Templates are rendered on use without modifying the original
+	rendered := original
+	assert.NoError(t, Render(&rendered, map[string]any{"Os": "linux"}))
+	assert.Equal(t, "{{ .Os }}", rendered.Name)
+	assert.Equal(t, Template("-X main.os=linux"), rendered.Ldflags)
+	assert.Equal(t, []Template{"-tags=linux"}, rendered.Flags)
+	assert.Equal(t, []Template{"-tags={{ .Os }}"}, original.Flags)
+}
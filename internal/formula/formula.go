@@ -3,6 +3,7 @@ package formula
 import (
 	_ "embed"
 	"fmt"
+	"slices"
 	"strings"
 	"text/template"
 )
@@ -29,10 +30,52 @@ func New() *Client {
 
 // Artifact represents downloadable artifact information
 type Artifact struct {
-	OS     string
-	Arch   string
-	URL    string
-	SHA256 string
+	OS       string
+	Arch     string
+	Variant  string
+	URL      string
+	SHA256   string
+	Binaries []Binary
+}
+
+// Binary represents a binary installed from an artifact
+type Binary struct {
+	Src  string // Path in the downloaded artifact
+	Name string // Installed name
+}
+
+// platform holds template data of a single platform
+type platform struct {
+	URL     string
+	SHA256  string
+	Install []string
+}
+
+// branch selects the platform of a CPU within an OS block (e.g. if Hardware::CPU.arm?)
+type branch struct {
+	Keyword   string // if, elsif or else
+	Condition string
+	*platform
+}
+
+// block holds the branches of an OS (on_macos or on_linux)
+type block struct {
+	Name     string
+	Branches []branch
+}
+
+// cpuCondition is a CPU condition of a platform
+type cpuCondition struct {
+	condition string
+	platform  *platform
+}
+
+// preferredVariants lists micro-architecture variants in order of preference.
+// Homebrew installs a single artifact per platform, so prefer the one that runs on most CPUs.
+var preferredVariants = map[string][]string{
+	"amd64": {"", "v1"},
+	"arm64": {"", "v8.0"},
+	"arm":   {"7", "", "6", "5"},
 }
 
 // Generate generates Homebrew Formula content
@@ -45,38 +88,77 @@ func (c *Client) Generate(formula *Formula) (string, error) {
 
 	// Prepare template data
 	data := struct {
-		ClassName        string
-		Version          string
-		ModuleName       string
-		MacOSARM64URL    string
-		MacOSARM64SHA256 string
-		MacOSAMD64URL    string
-		MacOSAMD64SHA256 string
-		LinuxARM64URL    string
-		LinuxARM64SHA256 string
-		LinuxAMD64URL    string
-		LinuxAMD64SHA256 string
+		ClassName string
+		Version   string
+		Blocks    []block
+		// Install holds install statements shared by every platform.
+		// It is empty when they differ, in which case each platform has its own install method.
+		Install []string
 	}{
-		ClassName:  className,
-		Version:    version,
-		ModuleName: formula.Name,
+		ClassName: className,
+		Version:   version,
 	}
 
-	// Set artifact information to template data
+	// Pick the preferred variant for each platform
+	selected := map[string]Artifact{}
 	for _, artifact := range formula.Artifacts {
-		switch {
-		case artifact.OS == "darwin" && artifact.Arch == "arm64":
-			data.MacOSARM64URL = artifact.URL
-			data.MacOSARM64SHA256 = artifact.SHA256
-		case artifact.OS == "darwin" && artifact.Arch == "amd64":
-			data.MacOSAMD64URL = artifact.URL
-			data.MacOSAMD64SHA256 = artifact.SHA256
-		case artifact.OS == "linux" && artifact.Arch == "arm64":
-			data.LinuxARM64URL = artifact.URL
-			data.LinuxARM64SHA256 = artifact.SHA256
-		case artifact.OS == "linux" && artifact.Arch == "amd64":
-			data.LinuxAMD64URL = artifact.URL
-			data.LinuxAMD64SHA256 = artifact.SHA256
+		key := artifact.OS + "/" + artifact.Arch
+		if current, ok := selected[key]; ok && c.variantRank(current) <= c.variantRank(artifact) {
+			continue
+		}
+		selected[key] = artifact
+	}
+
+	// Convert the artifacts of supported platforms
+	platforms := map[string]*platform{}
+	var installs [][]string
+	for key, artifact := range selected {
+		switch key {
+		case "darwin/arm64", "darwin/amd64", "linux/arm64", "linux/amd64", "linux/arm":
+		default:
+			continue
+		}
+		p := &platform{
+			URL:     artifact.URL,
+			SHA256:  artifact.SHA256,
+			Install: c.installStatements(formula, artifact),
+		}
+		platforms[key] = p
+		installs = append(installs, p.Install)
+	}
+	if len(platforms) == 0 {
+		return "", fmt.Errorf("no artifacts for macOS or Linux on amd64, arm64 or arm")
+	}
+
+	// Share install statements if every platform installs the same way
+	data.Install = installs[0]
+	for _, install := range installs[1:] {
+		if !slices.Equal(install, data.Install) {
+			data.Install = nil
+			break
+		}
+	}
+	if data.Install != nil {
+		for _, p := range platforms {
+			p.Install = nil
+		}
+	}
+
+	// Only platforms with an artifact are included, so Homebrew refuses to install on the others
+	linuxARM64 := "Hardware::CPU.arm?"
+	linuxARM := "Hardware::CPU.arm?"
+	switch {
+	case platforms["linux/arm64"] != nil && platforms["linux/arm"] != nil:
+		linuxARM64 = "Hardware::CPU.arm? && Hardware::CPU.is_64_bit?"
+	case platforms["linux/arm"] != nil:
+		linuxARM = "Hardware::CPU.arm? && !Hardware::CPU.is_64_bit?"
+	}
+	for _, b := range []block{
+		c.block("on_macos", platforms["darwin/arm64"] != nil, cpuCondition{"Hardware::CPU.arm?", platforms["darwin/arm64"]}, cpuCondition{"Hardware::CPU.intel?", platforms["darwin/amd64"]}),
+		c.block("on_linux", platforms["linux/arm64"] != nil && platforms["linux/arm"] != nil, cpuCondition{linuxARM64, platforms["linux/arm64"]}, cpuCondition{linuxARM, platforms["linux/arm"]}, cpuCondition{"Hardware::CPU.intel?", platforms["linux/amd64"]}),
+	} {
+		if len(b.Branches) > 0 {
+			data.Blocks = append(data.Blocks, b)
 		}
 	}
 
@@ -94,6 +176,59 @@ func (c *Client) Generate(formula *Formula) (string, error) {
 	return buf.String(), nil
 }
 
+// block returns the OS block of the platforms that have an artifact.
+// Intel is written as else when the ARM conditions before it cover every ARM CPU (armCovered),
+// so ARM CPUs without an artifact aren't given the Intel binary.
+func (c *Client) block(name string, armCovered bool, conditions ...cpuCondition) block {
+	b := block{Name: name}
+	for _, cond := range conditions {
+		if cond.platform == nil {
+			continue
+		}
+		keyword := "elsif"
+		if len(b.Branches) == 0 {
+			keyword = "if"
+		}
+		b.Branches = append(b.Branches, branch{Keyword: keyword, Condition: cond.condition, platform: cond.platform})
+	}
+
+	if n := len(b.Branches); n > 1 && armCovered && b.Branches[n-1].Condition == "Hardware::CPU.intel?" {
+		b.Branches[n-1].Keyword = "else"
+		b.Branches[n-1].Condition = ""
+	}
+	return b
+}
+
+// installStatements returns the Ruby statements that install the binaries of an artifact
+func (c *Client) installStatements(formula *Formula, artifact Artifact) []string {
+	// Install the binary named after the formula by default
+	binaries := artifact.Binaries
+	if len(binaries) == 0 {
+		binaries = []Binary{{Src: formula.Name, Name: formula.Name}}
+	}
+
+	var statements []string
+	for _, binary := range binaries {
+		if binary.Src == binary.Name {
+			statements = append(statements, fmt.Sprintf("bin.install %q", binary.Name))
+		} else {
+			statements = append(statements, fmt.Sprintf("bin.install %q => %q", binary.Src, binary.Name))
+		}
+	}
+	return statements
+}
+
+// variantRank returns the preference of an artifact's variant (lower is better)
+func (c *Client) variantRank(artifact Artifact) int {
+	variants := preferredVariants[artifact.Arch]
+	for i, variant := range variants {
+		if artifact.Variant == variant {
+			return i
+		}
+	}
+	return len(variants)
+}
+
 // toClassName generates class name from module name
 func (c *Client) toClassName(moduleName string) string {
 	// Get the last part of the path
@@ -0,0 +1,177 @@
+package formula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Generate(t *testing.T) {
+	artifact := func(os, arch, variant string) Artifact {
+		return Artifact{
+			OS:      os,
+			Arch:    arch,
+			Variant: variant,
+			URL:     "https://example.com/app_" + os + "_" + arch + variant + ".tar.gz",
+			SHA256:  os + arch + variant,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		artifacts []Artifact
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name: "full matrix",
+			artifacts: []Artifact{
+				artifact("darwin", "amd64", ""),
+				artifact("darwin", "arm64", ""),
+				artifact("linux", "amd64", ""),
+				artifact("linux", "arm64", ""),
+				artifact("linux", "arm", "6"),
+				artifact("linux", "arm", "7"),
+				artifact("windows", "amd64", ""),
+			},
+			expected: `# typed: strict
+# frozen_string_literal: true
+
+# App formula
+class App < Formula
+  version "1.2.3"
+
+  on_macos do
+    if Hardware::CPU.arm?
+      url "https://example.com/app_darwin_arm64.tar.gz"
+      sha256 "darwinarm64"
+    else
+      url "https://example.com/app_darwin_amd64.tar.gz"
+      sha256 "darwinamd64"
+    end
+  end
+
+  on_linux do
+    if Hardware::CPU.arm? && Hardware::CPU.is_64_bit?
+      url "https://example.com/app_linux_arm64.tar.gz"
+      sha256 "linuxarm64"
+    elsif Hardware::CPU.arm?
+      url "https://example.com/app_linux_arm7.tar.gz"
+      sha256 "linuxarm7"
+    else
+      url "https://example.com/app_linux_amd64.tar.gz"
+      sha256 "linuxamd64"
+    end
+  end
+
+  def install
+    bin.install "app"
+  end
+end`,
+		},
+		{
+			name: "partial matrix omits missing platforms",
+			artifacts: []Artifact{
+				artifact("linux", "amd64", ""),
+				artifact("linux", "arm", "7"),
+				artifact("darwin", "arm64", ""),
+			},
+			expected: `# typed: strict
+# frozen_string_literal: true
+
+# App formula
+class App < Formula
+  version "1.2.3"
+
+  on_macos do
+    if Hardware::CPU.arm?
+      url "https://example.com/app_darwin_arm64.tar.gz"
+      sha256 "darwinarm64"
+    end
+  end
+
+  on_linux do
+    if Hardware::CPU.arm? && !Hardware::CPU.is_64_bit?
+      url "https://example.com/app_linux_arm7.tar.gz"
+      sha256 "linuxarm7"
+    elsif Hardware::CPU.intel?
+      url "https://example.com/app_linux_amd64.tar.gz"
+      sha256 "linuxamd64"
+    end
+  end
+
+  def install
+    bin.install "app"
+  end
+end`,
+		},
+		{
+			name: "intel is not the fallback of uncovered ARM CPUs",
+			artifacts: []Artifact{
+				artifact("linux", "amd64", ""),
+				artifact("linux", "arm64", ""),
+			},
+			expected: `# typed: strict
+# frozen_string_literal: true
+
+# App formula
+class App < Formula
+  version "1.2.3"
+
+  on_linux do
+    if Hardware::CPU.arm?
+      url "https://example.com/app_linux_arm64.tar.gz"
+      sha256 "linuxarm64"
+    elsif Hardware::CPU.intel?
+      url "https://example.com/app_linux_amd64.tar.gz"
+      sha256 "linuxamd64"
+    end
+  end
+
+  def install
+    bin.install "app"
+  end
+end`,
+		},
+		{
+			name:      "linux amd64 only",
+			artifacts: []Artifact{artifact("linux", "amd64", "")},
+			expected: `# typed: strict
+# frozen_string_literal: true
+
+# App formula
+class App < Formula
+  version "1.2.3"
+
+  on_linux do
+    if Hardware::CPU.intel?
+      url "https://example.com/app_linux_amd64.tar.gz"
+      sha256 "linuxamd64"
+    end
+  end
+
+  def install
+    bin.install "app"
+  end
+end`,
+		},
+		{
+			name:      "no supported platform",
+			artifacts: []Artifact{artifact("windows", "amd64", "")},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New().Generate(&Formula{Name: "app", Version: "v1.2.3", Artifacts: tt.artifacts})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
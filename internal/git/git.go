@@ -1,24 +1,41 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Remote URL patterns of GitHub and GitLab repositories (including self-hosted instances).
+// GitLab projects may be nested in subgroups, so the owner is everything before the last path segment.
 var (
-	httpsPattern = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+?)(?:\.git)?/?$`)
-	sshPattern   = regexp.MustCompile(`^git@github\.com:([^/]+)/([^/]+?)(?:\.git)?$`)
+	httpsPattern = regexp.MustCompile(`^https?://(?:[^@/]+@)?([^@/]+)/((?:[^/]+/)*[^/]+)/([^/]+?)(?:\.git)?/?$`)
+	sshPattern   = regexp.MustCompile(`^ssh://(?:[^@/]+@)?([^@/:]+)(?::\d+)?/((?:[^/]+/)*[^/]+)/([^/]+?)(?:\.git)?/?$`)
+	scpPattern   = regexp.MustCompile(`^(?:[^@/]+@)?([^@/:]+):((?:[^/]+/)*[^/]+)/([^/]+?)(?:\.git)?/?$`)
 )
 
-// Repository represents a GitHub repository
+// Repository represents a GitHub, GitLab or Gitea repository
 type Repository struct {
-	Owner string
+	Host  string // Host of the remote (e.g. github.com), empty if unknown
+	Owner string // User or organization, or the full group path on GitLab (e.g. group/subgroup)
 	Name  string
 }
 
+// Commit represents a single commit
+type Commit struct {
+	Hash        string
+	ShortHash   string
+	Subject     string
+	AuthorName  string
+	AuthorEmail string
+}
+
 // Client provides Git operations
 type Client struct{}
 
@@ -37,19 +54,127 @@ func (c *Client) GetHeadTag() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetRepository retrieves GitHub repository information
+// GetCommit retrieves the full SHA of the HEAD commit
+func (c *Client) GetCommit() (string, error) {
+	return c.revParse("HEAD")
+}
+
+// GetShortCommit retrieves the abbreviated SHA of the HEAD commit
+func (c *Client) GetShortCommit() (string, error) {
+	return c.revParse("--short", "HEAD")
+}
+
+// GetCommitDate retrieves the committer date of the HEAD commit
+func (c *Client) GetCommitDate() (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get commit date: %w", err)
+	}
+
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commit date: %w", err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// GetPreviousTag retrieves the latest tag before HEAD.
+// An empty string is returned if there is no previous tag.
+func (c *Client) GetPreviousTag() (string, error) {
+	// Fail silently when HEAD has no parent
+	if _, err := c.revParse("--verify", "--quiet", "HEAD^"); err != nil {
+		return "", nil
+	}
+
+	cmd := exec.Command("git", "describe", "--tags", "--abbrev=0", "HEAD^")
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// No tag is reachable from HEAD^
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get previous tag: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetLog retrieves the commits reachable from to but not from, newest first.
+// Every commit reachable from to is returned if from is empty.
+func (c *Client) GetLog(from, to string) ([]Commit, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+
+	// Separate fields and commits with control characters that don't appear in commit subjects
+	cmd := exec.Command("git", "log", "--no-merges", "--format=%H%x1f%h%x1f%s%x1f%an%x1f%ae%x1e", rev)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git log: %w", err)
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(output), "\x1e") {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("failed to parse git log: %q", record)
+		}
+		commits = append(commits, Commit{
+			Hash:        fields[0],
+			ShortHash:   fields[1],
+			Subject:     fields[2],
+			AuthorName:  fields[3],
+			AuthorEmail: fields[4],
+		})
+	}
+	return commits, nil
+}
+
+// revParse runs git rev-parse with the given arguments
+func (c *Client) revParse(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"rev-parse"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", args[len(args)-1], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetRepository retrieves repository information from CI environment variables or the origin remote
 func (c *Client) GetRepository() (*Repository, error) {
-	// Prefer environment variable
+	// Prefer environment variables set by GitHub Actions (also Gitea and Forgejo Actions) and GitLab CI
 	if env := os.Getenv("GITHUB_REPOSITORY"); env != "" {
 		parts := strings.SplitN(env, "/", 2)
 		if len(parts) == 2 {
+			host := ""
+			if server, err := url.Parse(os.Getenv("GITHUB_SERVER_URL")); err == nil {
+				host = strings.ToLower(server.Host)
+			}
 			return &Repository{
+				Host:  host,
 				Owner: parts[0],
 				Name:  parts[1],
 			}, nil
 		}
 		return nil, fmt.Errorf("invalid GITHUB_REPOSITORY format: %s (expected owner/repo)", env)
 	}
+	if env := os.Getenv("CI_PROJECT_PATH"); env != "" {
+		i := strings.LastIndex(env, "/")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid CI_PROJECT_PATH format: %s (expected group/project)", env)
+		}
+		return &Repository{
+			Host:  strings.ToLower(os.Getenv("CI_SERVER_HOST")),
+			Owner: env[:i],
+			Name:  env[i+1:],
+		}, nil
+	}
 
 	// Get from git remote
 	cmd := exec.Command("git", "remote", "get-url", "origin")
@@ -58,22 +183,33 @@ func (c *Client) GetRepository() (*Repository, error) {
 		return nil, fmt.Errorf("failed to get git remote origin: %w", err)
 	}
 
-	remoteURL := strings.TrimSpace(string(output))
+	return ParseRemoteURL(strings.TrimSpace(string(output)))
+}
 
-	// Parse URL
+// ParseRemoteURL parses the URL of a GitHub, GitLab or Gitea remote
+func ParseRemoteURL(remoteURL string) (*Repository, error) {
 	var matches []string
-	if httpsPattern.MatchString(remoteURL) {
-		matches = httpsPattern.FindStringSubmatch(remoteURL)
-	} else if sshPattern.MatchString(remoteURL) {
-		matches = sshPattern.FindStringSubmatch(remoteURL)
+	for _, pattern := range []*regexp.Regexp{httpsPattern, sshPattern, scpPattern} {
+		if matches = pattern.FindStringSubmatch(remoteURL); matches != nil {
+			break
+		}
 	}
 
-	if len(matches) != 3 {
-		return nil, fmt.Errorf("invalid GitHub repository URL: %s", remoteURL)
+	if len(matches) != 4 {
+		return nil, fmt.Errorf("invalid repository URL: %s", remoteURL)
 	}
 
-	return &Repository{
-		Owner: matches[1],
-		Name:  matches[2],
-	}, nil
+	repo := &Repository{
+		Host:  strings.ToLower(matches[1]),
+		Owner: matches[2],
+		Name:  matches[3],
+	}
+	// GitHub serves SSH over port 443 as ssh.github.com
+	if repo.Host == "ssh.github.com" {
+		repo.Host = "github.com"
+	}
+	if repo.Host == "github.com" && strings.Contains(repo.Owner, "/") {
+		return nil, fmt.Errorf("invalid GitHub repository URL: %s", remoteURL)
+	}
+	return repo, nil
 }
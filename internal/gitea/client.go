@@ -0,0 +1,317 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+)
+
+// pageSize is the number of items requested per page, the default maximum of Gitea
+const pageSize = 50
+
+// Client is a Gitea API client, which works with Forgejo as well.
+// Gitea instances are always self-hosted, so the API URL is required.
+type Client struct {
+	api *backend.APIClient
+}
+
+// Options configures a Client
+type Options struct {
+	APIURL        string // e.g. https://gitea.example.com/api/v1/
+	SkipTLSVerify bool   // Don't verify TLS certificates, e.g. of self-signed instances
+}
+
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
+
+// release is a release of the Releases API
+type release struct {
+	ID      int64  `json:"id"`
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	Body    string `json:"body"`
+	Draft   bool   `json:"draft"`
+}
+
+// attachment is an asset of a release
+type attachment struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// New creates a new Gitea client
+func New(token string, options Options) (*Client, error) {
+	if options.APIURL == "" {
+		return nil, fmt.Errorf("Gitea API URL is required")
+	}
+	api, err := backend.NewAPIClient(backend.APIOptions{
+		Name:          "Gitea",
+		URL:           options.APIURL,
+		AuthHeader:    "Authorization",
+		Auth:          "token " + token,
+		SkipTLSVerify: options.SkipTLSVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{api: api}, nil
+}
+
+// GetReleaseByTag retrieves a release by tag name, including drafts.
+// nil is returned if there is no such release.
+func (c *Client) GetReleaseByTag(params backend.GetReleaseByTagParams) (*backend.Release, error) {
+	var r release
+	err := c.api.Do(http.MethodGet, repoPath(params.Owner, params.Repo)+"/releases/tags/"+url.PathEscape(params.Tag), nil, &r)
+	if err == nil {
+		return toRelease(&r), nil
+	}
+	if !backend.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	// Drafts aren't found by tag on every version, so look for them in the list
+	for page := 1; ; page++ {
+		var releases []*release
+		path := fmt.Sprintf("%s/releases?draft=true&limit=%d&page=%d", repoPath(params.Owner, params.Repo), pageSize, page)
+		if err := c.api.Do(http.MethodGet, path, nil, &releases); err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, r := range releases {
+			if r.TagName == params.Tag {
+				return toRelease(r), nil
+			}
+		}
+		if len(releases) < pageSize {
+			return nil, nil
+		}
+	}
+}
+
+// CreateRelease creates a new release. Gitea has no latest flag, so MakeLatest is ignored.
+func (c *Client) CreateRelease(params backend.CreateReleaseParams) (*backend.Release, error) {
+	body := map[string]any{
+		"tag_name":   params.Tag,
+		"name":       params.Name,
+		"body":       params.Body,
+		"draft":      params.Draft,
+		"prerelease": params.Prerelease,
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPost, repoPath(params.Owner, params.Repo)+"/releases", body, &r); err != nil {
+		return nil, fmt.Errorf("failed to create release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// UpdateRelease updates the name and body of a release. The name is left as is if empty.
+func (c *Client) UpdateRelease(params backend.UpdateReleaseParams) (*backend.Release, error) {
+	body := map[string]any{
+		"body": params.Body,
+	}
+	if params.Name != "" {
+		body["name"] = params.Name
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPatch, releasePath(params.Owner, params.Repo, params.Release.ID), body, &r); err != nil {
+		return nil, fmt.Errorf("failed to update release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// PublishRelease publishes a draft release
+func (c *Client) PublishRelease(params backend.PublishReleaseParams) (*backend.Release, error) {
+	body := map[string]any{
+		"draft": false,
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPatch, releasePath(params.Owner, params.Repo, params.Release.ID), body, &r); err != nil {
+		return nil, fmt.Errorf("failed to publish release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// DeleteRelease deletes a release. The tag is kept.
+func (c *Client) DeleteRelease(params backend.DeleteReleaseParams) error {
+	if err := c.api.Do(http.MethodDelete, releasePath(params.Owner, params.Repo, params.Release.ID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete release: %w", err)
+	}
+
+	return nil
+}
+
+// ListReleaseAssets lists every attachment of a release. Gitea doesn't expose digests.
+func (c *Client) ListReleaseAssets(params backend.ListReleaseAssetsParams) ([]*backend.Asset, error) {
+	var assets []*backend.Asset
+	for page := 1; ; page++ {
+		var list []*attachment
+		path := fmt.Sprintf("%s/assets?limit=%d&page=%d", releasePath(params.Owner, params.Repo, params.Release.ID), pageSize, page)
+		if err := c.api.Do(http.MethodGet, path, nil, &list); err != nil {
+			return nil, fmt.Errorf("failed to list release assets: %w", err)
+		}
+		for _, a := range list {
+			assets = append(assets, &backend.Asset{ID: a.ID, Name: a.Name, Size: a.Size, State: "uploaded"})
+		}
+		if len(list) < pageSize {
+			return assets, nil
+		}
+	}
+}
+
+// UploadAsset uploads a file as an attachment of a release
+func (c *Client) UploadAsset(ctx context.Context, params backend.UploadAssetParams) error {
+	file, err := os.Open(params.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open asset file %s: %w", params.Path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat asset file %s: %w", params.Path, err)
+	}
+
+	// Build the multipart envelope around the file so it can be streamed with an explicit length
+	var envelope bytes.Buffer
+	mw := multipart.NewWriter(&envelope)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachment"; filename="%s"`, quoteEscaper.Replace(params.Name)))
+	header.Set("Content-Type", backend.MediaType(params.Name))
+	if _, err := mw.CreatePart(header); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+	headLen := envelope.Len()
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+	head, tail := envelope.Bytes()[:headLen], envelope.Bytes()[headLen:]
+
+	var content io.Reader = file
+	if params.Progress != nil {
+		content = backend.NewProgressReader(file, stat.Size(), params.Progress)
+	}
+	path := fmt.Sprintf("%s/assets?name=%s", releasePath(params.Owner, params.Repo, params.Release.ID), url.QueryEscape(params.Name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.api.URL+path, io.MultiReader(bytes.NewReader(head), content, bytes.NewReader(tail)))
+	if err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+	req.ContentLength = int64(len(head)) + stat.Size() + int64(len(tail))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, err := c.api.Send(req, nil); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteReleaseAsset deletes an attachment of a release
+func (c *Client) DeleteReleaseAsset(params backend.DeleteReleaseAssetParams) error {
+	path := fmt.Sprintf("%s/assets/%d", releasePath(params.Owner, params.Repo, params.Release.ID), params.Asset.ID)
+	if err := c.api.Do(http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete release asset: %w", err)
+	}
+
+	return nil
+}
+
+// DownloadAsset writes the content of a release asset to w
+func (c *Client) DownloadAsset(params backend.DownloadAssetParams, w io.Writer) error {
+	// Attachments of drafts are only served by their UUID URL
+	var a attachment
+	path := fmt.Sprintf("%s/assets/%d", releasePath(params.Owner, params.Repo, params.Release.ID), params.Asset.ID)
+	if err := c.api.Do(http.MethodGet, path, nil, &a); err != nil {
+		return fmt.Errorf("failed to get release asset: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, a.BrowserDownloadURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+	if _, err := c.api.Send(req, w); err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateFile creates or updates a file on the default branch of the repository
+func (c *Client) UpdateFile(params backend.UpdateFileParams) error {
+	path := repoPath(params.Owner, params.Repo) + "/contents/" + escapePath(params.Path)
+
+	// Check existing file
+	var existing struct {
+		SHA string `json:"sha"`
+	}
+	method := http.MethodPut
+	if err := c.api.Do(http.MethodGet, path, nil, &existing); err != nil {
+		if !backend.IsNotFound(err) {
+			return fmt.Errorf("failed to check existing file: %w", err)
+		}
+		method = http.MethodPost
+	}
+
+	// Create or update file
+	body := map[string]any{
+		"content": base64.StdEncoding.EncodeToString([]byte(params.Content)),
+		"message": params.CommitMessage,
+	}
+	if method == http.MethodPut {
+		body["sha"] = existing.SHA
+	}
+	if err := c.api.Do(method, path, body, nil); err != nil {
+		return fmt.Errorf("failed to update file: %w", err)
+	}
+
+	return nil
+}
+
+// quoteEscaper escapes file names in multipart headers
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// repoPath returns the API path of a repository
+func repoPath(owner, repo string) string {
+	return "repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+// releasePath returns the API path of a release
+func releasePath(owner, repo string, id int64) string {
+	return fmt.Sprintf("%s/releases/%d", repoPath(owner, repo), id)
+}
+
+// escapePath escapes each segment of a file path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// toRelease converts a Gitea release
+func toRelease(r *release) *backend.Release {
+	return &backend.Release{
+		ID:    r.ID,
+		Tag:   r.TagName,
+		Name:  r.Name,
+		Body:  r.Body,
+		Draft: r.Draft,
+	}
+}
@@ -0,0 +1,358 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+	"github.com/koki-develop/gorocket/internal/backend/backendtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitea is an in-memory stand-in for the Gitea Releases and Contents APIs
+type fakeGitea struct {
+	*backendtest.Server
+
+	releases    map[int64]*release
+	attachments map[int64][]*fakeAttachment // by release ID
+	files       map[string]string           // repository files by path
+	commits     []string                    // commit messages
+	nextID      int64
+}
+
+type fakeAttachment struct {
+	attachment
+	content     []byte
+	contentType string
+}
+
+var (
+	releaseTagPattern  = regexp.MustCompile(`^/api/v1/repos/owner/app/releases/tags/([^/]+)$`)
+	releasePattern     = regexp.MustCompile(`^/api/v1/repos/owner/app/releases/(\d+)$`)
+	assetsPattern      = regexp.MustCompile(`^/api/v1/repos/owner/app/releases/(\d+)/assets(?:/(\d+))?$`)
+	attachmentPattern  = regexp.MustCompile(`^/attachments/(\d+)$`)
+	contentsPathPrefix = "/api/v1/repos/owner/homebrew-tap/contents/"
+)
+
+func newFakeGitea(t *testing.T) (*fakeGitea, *Client) {
+	t.Helper()
+
+	f := &fakeGitea{
+		releases:    map[int64]*release{},
+		attachments: map[int64][]*fakeAttachment{},
+		files:       map[string]string{},
+	}
+	f.Server = backendtest.NewServer(t, f.handle)
+
+	c, err := New("token", Options{APIURL: f.URL + "/api/v1"})
+	require.NoError(t, err)
+	return f, c
+}
+
+func (f *fakeGitea) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "token token" {
+		f.Reply(w, http.StatusUnauthorized, map[string]any{"message": "token is required"})
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	var m []string
+	match := func(pattern *regexp.Regexp) bool {
+		m = pattern.FindStringSubmatch(path)
+		return m != nil
+	}
+
+	switch {
+	case r.Method == http.MethodPost && path == "/api/v1/repos/owner/app/releases":
+		var body release
+		if !f.Decode(w, r, &body) {
+			return
+		}
+		for _, existing := range f.releases {
+			if existing.TagName == body.TagName {
+				f.Reply(w, http.StatusConflict, map[string]any{"message": "Release already exists"})
+				return
+			}
+		}
+		f.nextID++
+		body.ID = f.nextID
+		f.releases[body.ID] = &body
+		f.Reply(w, http.StatusCreated, body)
+
+	case r.Method == http.MethodGet && path == "/api/v1/repos/owner/app/releases":
+		assert.Equal(f.T, "true", r.URL.Query().Get("draft"))
+		list := []*release{}
+		for _, rel := range f.releases {
+			if rel.Draft {
+				list = append(list, rel)
+			}
+		}
+		f.Reply(w, http.StatusOK, list)
+
+	case r.Method == http.MethodGet && match(releaseTagPattern):
+		// Drafts aren't found by tag
+		for _, rel := range f.releases {
+			if rel.TagName == m[1] && !rel.Draft {
+				f.Reply(w, http.StatusOK, rel)
+				return
+			}
+		}
+		f.Reply(w, http.StatusNotFound, map[string]any{"message": "The target couldn't be found."})
+
+	case match(releasePattern):
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		rel, ok := f.releases[id]
+		if !ok {
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "The target couldn't be found."})
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			if !f.Decode(w, r, rel) {
+				return
+			}
+			f.Reply(w, http.StatusOK, rel)
+		case http.MethodDelete:
+			delete(f.releases, id)
+			delete(f.attachments, id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case match(assetsPattern):
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		switch {
+		case r.Method == http.MethodPost:
+			assert.Greater(f.T, r.ContentLength, int64(0))
+			file, header, err := r.FormFile("attachment")
+			if !f.Check(w, err) {
+				return
+			}
+			assert.Equal(f.T, r.URL.Query().Get("name"), header.Filename)
+			content, err := io.ReadAll(file)
+			if !f.Check(w, err) {
+				return
+			}
+			f.nextID++
+			a := &fakeAttachment{
+				attachment: attachment{
+					ID:                 f.nextID,
+					Name:               r.URL.Query().Get("name"),
+					Size:               int64(len(content)),
+					BrowserDownloadURL: fmt.Sprintf("%s/attachments/%d", f.URL, f.nextID),
+				},
+				content:     content,
+				contentType: header.Header.Get("Content-Type"),
+			}
+			f.attachments[id] = append(f.attachments[id], a)
+			f.Reply(w, http.StatusCreated, a.attachment)
+		case r.Method == http.MethodGet && m[2] == "":
+			list := []attachment{}
+			for _, a := range f.attachments[id] {
+				list = append(list, a.attachment)
+			}
+			f.Reply(w, http.StatusOK, list)
+		default:
+			assetID, _ := strconv.ParseInt(m[2], 10, 64)
+			for i, a := range f.attachments[id] {
+				if a.ID != assetID {
+					continue
+				}
+				if r.Method == http.MethodDelete {
+					f.attachments[id] = append(f.attachments[id][:i], f.attachments[id][i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+				} else {
+					f.Reply(w, http.StatusOK, a.attachment)
+				}
+				return
+			}
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "The target couldn't be found."})
+		}
+
+	case r.Method == http.MethodGet && match(attachmentPattern):
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		for _, list := range f.attachments {
+			for _, a := range list {
+				if a.ID == id {
+					_, _ = w.Write(a.content)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	case strings.HasPrefix(path, contentsPathPrefix):
+		assert.Equal(f.T, "Formula/app.rb", strings.TrimPrefix(path, contentsPathPrefix))
+		content, exists := f.files["Formula/app.rb"]
+		sha := fmt.Sprintf("sha-%d", len(f.commits))
+		switch r.Method {
+		case http.MethodGet:
+			if !exists {
+				f.Reply(w, http.StatusNotFound, map[string]any{"message": "GetContentsOrList"})
+				return
+			}
+			f.Reply(w, http.StatusOK, map[string]any{"sha": sha, "content": base64.StdEncoding.EncodeToString([]byte(content))})
+		case http.MethodPost, http.MethodPut:
+			var body map[string]string
+			if !f.Decode(w, r, &body) {
+				return
+			}
+			if exists != (r.Method == http.MethodPut) || (exists && body["sha"] != sha) {
+				f.Reply(w, http.StatusUnprocessableEntity, map[string]any{"message": "repository file already exists or sha is wrong"})
+				return
+			}
+			decoded, err := base64.StdEncoding.DecodeString(body["content"])
+			if !f.Check(w, err) {
+				return
+			}
+			f.files["Formula/app.rb"] = string(decoded)
+			f.commits = append(f.commits, body["message"])
+			f.Reply(w, http.StatusCreated, map[string]any{"content": map[string]any{"path": "Formula/app.rb"}})
+		}
+
+	default:
+		f.Unexpected(w, r)
+	}
+}
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "trailing slash is added",
+			options: Options{APIURL: "https://gitea.example.com/api/v1"},
+			want:    "https://gitea.example.com/api/v1/",
+		},
+		{
+			name:    "API URL is required",
+			options: Options{},
+			wantErr: "Gitea API URL is required",
+		},
+		{
+			name:    "invalid API URL",
+			options: Options{APIURL: "gitea.example.com"},
+			wantErr: "invalid Gitea API URL: gitea.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New("token", tt.options)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.api.URL)
+		})
+	}
+}
+
+func Test_Release(t *testing.T) {
+	f, c := newFakeGitea(t)
+	dir := t.TempDir()
+	assets := map[string][]byte{
+		"app_linux_amd64.tar.gz": bytes.Repeat([]byte("gorocket"), 4096),
+		"checksums.txt":          []byte("abc  app_linux_amd64.tar.gz\n"),
+	}
+
+	// Missing releases are nil
+	release, err := c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: "owner", Repo: "app", Tag: "v1.0.0"})
+	require.NoError(t, err)
+	assert.Nil(t, release)
+
+	created, err := c.CreateRelease(backend.CreateReleaseParams{Owner: "owner", Repo: "app", Tag: "v1.0.0", Name: "v1.0.0", Body: "notes", Draft: true})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{ID: 1, Tag: "v1.0.0", Name: "v1.0.0", Body: "notes", Draft: true}, created)
+
+	// Drafts are found in the list of releases
+	release, err = c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: "owner", Repo: "app", Tag: "v1.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, created, release)
+
+	// Upload assets as multipart attachments
+	for _, name := range []string{"app_linux_amd64.tar.gz", "checksums.txt"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, assets[name], 0644))
+
+		var written int64
+		require.NoError(t, c.UploadAsset(context.Background(), backend.UploadAssetParams{
+			Owner:    "owner",
+			Repo:     "app",
+			Release:  release,
+			Name:     name,
+			Path:     path,
+			Progress: func(w, total int64) { written = w },
+		}))
+		assert.Equal(t, int64(len(assets[name])), written)
+	}
+	assert.Equal(t, "application/gzip", f.attachments[release.ID][0].contentType)
+
+	list, err := c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "owner", Repo: "app", Release: release})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	for _, asset := range list {
+		assert.Equal(t, int64(len(assets[asset.Name])), asset.Size)
+		assert.Equal(t, "uploaded", asset.State)
+		assert.Empty(t, asset.Digest)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DownloadAsset(backend.DownloadAssetParams{Owner: "owner", Repo: "app", Release: release, Asset: list[0]}, &buf))
+	assert.Equal(t, assets[list[0].Name], buf.Bytes())
+
+	require.NoError(t, c.DeleteReleaseAsset(backend.DeleteReleaseAssetParams{Owner: "owner", Repo: "app", Release: release, Asset: list[1]}))
+	assert.Len(t, f.attachments[release.ID], 1)
+
+	release, err = c.PublishRelease(backend.PublishReleaseParams{Owner: "owner", Repo: "app", Release: release})
+	require.NoError(t, err)
+	assert.False(t, release.Draft)
+
+	// Published releases are found by tag
+	release, err = c.UpdateRelease(backend.UpdateReleaseParams{Owner: "owner", Repo: "app", Release: release, Body: "new notes"})
+	require.NoError(t, err)
+	got, err := c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: "owner", Repo: "app", Tag: "v1.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{ID: 1, Tag: "v1.0.0", Name: "v1.0.0", Body: "new notes"}, got)
+
+	require.NoError(t, c.DeleteRelease(backend.DeleteReleaseParams{Owner: "owner", Repo: "app", Release: release}))
+	assert.Empty(t, f.releases)
+}
+
+func Test_UpdateFile(t *testing.T) {
+	f, c := newFakeGitea(t)
+
+	for _, content := range []string{"class App < Formula; end", "class App < Formula; version '2'; end"} {
+		require.NoError(t, c.UpdateFile(backend.UpdateFileParams{
+			Owner:         "owner",
+			Repo:          "homebrew-tap",
+			Path:          "Formula/app.rb",
+			Content:       content,
+			CommitMessage: fmt.Sprintf("Update app (%d)", len(f.commits)+1),
+		}))
+		assert.Equal(t, content, f.files["Formula/app.rb"])
+	}
+	assert.Equal(t, []string{"Update app (1)", "Update app (2)"}, f.commits)
+}
+
+func Test_Error(t *testing.T) {
+	f, _ := newFakeGitea(t)
+
+	c, err := New("invalid", Options{APIURL: f.URL + "/api/v1"})
+	require.NoError(t, err)
+	_, err = c.CreateRelease(backend.CreateReleaseParams{Owner: "owner", Repo: "app", Tag: "v1.0.0"})
+	assert.EqualError(t, err, "failed to create release: Gitea API returned 401: token is required")
+}
@@ -2,144 +2,489 @@ package github
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/v66/github"
+	"github.com/koki-develop/gorocket/internal/backend"
 	"golang.org/x/oauth2"
 )
 
 // Client is a GitHub API client
 type Client struct {
-	client *github.Client
+	client       *github.Client
+	retryOptions RetryOptions
+	verbose      bool
+	log          io.Writer
+	sleep        func(ctx context.Context, d time.Duration) error
+
+	// downloadClient follows redirects of asset downloads without sending the token
+	downloadClient *http.Client
 }
 
-// GetReleaseByTagParams represents parameters for GetReleaseByTag
-type GetReleaseByTagParams struct {
-	Owner string
-	Repo  string
-	Tag   string
+// Options configures a Client
+type Options struct {
+	Retry         RetryOptions
+	Verbose       bool      // Print the rate limit state after each request
+	APIURL        string    // GitHub Enterprise Server API URL (e.g. https://github.example.com/api/v3/)
+	UploadURL     string    // GitHub Enterprise Server upload URL (defaults to /api/uploads/ of the API host)
+	SkipTLSVerify bool      // Don't verify TLS certificates, e.g. of self-signed instances
+	Log           io.Writer // Receives retry and rate limit messages (defaults to stderr)
 }
 
-// CreateReleaseParams represents parameters for CreateRelease
-type CreateReleaseParams struct {
+// GetCommitAuthorsParams represents parameters for GetCommitAuthors
+type GetCommitAuthorsParams struct {
 	Owner string
 	Repo  string
-	Tag   string
-	Name  string
-	Draft bool
+	Base  string
+	Head  string
 }
 
-// UploadAssetParams represents parameters for UploadAsset
-type UploadAssetParams struct {
-	Owner     string
-	Repo      string
-	ReleaseID int64
-	Asset     Asset
+// releaseAsset is a release asset including its digest, which go-github doesn't decode
+type releaseAsset struct {
+	github.ReleaseAsset
+	Digest string `json:"digest,omitempty"` // e.g. sha256:..., empty if GitHub hasn't computed it
 }
 
-// UpdateFileParams represents parameters for UpdateFile
-type UpdateFileParams struct {
-	Owner         string
-	Repo          string
-	Path          string
-	Content       string
-	CommitMessage string
-}
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
 
-// Asset represents a release asset
-type Asset struct {
-	Name string
-	Path string
-}
+// New creates a new GitHub client.
+// It targets GitHub Enterprise Server if options.APIURL is set.
+func New(token string, options Options) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.SkipTLSVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	httpClient := &http.Client{Transport: transport}
 
-// New creates a new GitHub client
-func New(token string) *Client {
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	tc := oauth2.NewClient(ctx, ts)
 
-	return &Client{
-		client: github.NewClient(tc),
+	client := github.NewClient(tc)
+	if options.APIURL != "" && !isPublicAPIURL(options.APIURL) {
+		// Uploads are served under /api/uploads/ of the same host by default
+		uploadURL := options.UploadURL
+		if uploadURL == "" {
+			uploadURL = strings.TrimSuffix(strings.TrimSuffix(options.APIURL, "/"), "/api/v3")
+		}
+		var err error
+		if client, err = client.WithEnterpriseURLs(options.APIURL, uploadURL); err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise Server URLs: %w", err)
+		}
+	}
+
+	log := options.Log
+	if log == nil {
+		log = os.Stderr
 	}
+
+	return &Client{
+		client:         client,
+		retryOptions:   options.Retry.withDefaults(),
+		verbose:        options.Verbose,
+		log:            log,
+		sleep:          sleepContext,
+		downloadClient: httpClient,
+	}, nil
+}
+
+// isPublicAPIURL reports whether url is the API of github.com, e.g. GITHUB_API_URL on github.com
+func isPublicAPIURL(url string) bool {
+	return strings.TrimSuffix(url, "/") == "https://api.github.com"
 }
 
-// GetReleaseByTag retrieves a release by tag name
-func (c *Client) GetReleaseByTag(params GetReleaseByTagParams) (*github.RepositoryRelease, error) {
+// GetReleaseByTag retrieves a release by tag name, including draft releases.
+// nil is returned if there is no such release.
+func (c *Client) GetReleaseByTag(params backend.GetReleaseByTagParams) (*backend.Release, error) {
 	ctx := context.Background()
-	release, resp, err := c.client.Repositories.GetReleaseByTag(ctx, params.Owner, params.Repo, params.Tag)
-	if err != nil {
-		if resp != nil && resp.StatusCode == http.StatusNotFound {
-			return nil, nil
-		}
+
+	var release *github.RepositoryRelease
+	resp, err := c.retry(ctx, func() (resp *github.Response, err error) {
+		release, resp, err = c.client.Repositories.GetReleaseByTag(ctx, params.Owner, params.Repo, params.Tag)
+		return resp, err
+	})
+	if err == nil {
+		return toRelease(release), nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
 		return nil, fmt.Errorf("failed to get release: %w", err)
 	}
 
-	return release, nil
+	// Draft releases can't be looked up by tag, so search the release list
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var releases []*github.RepositoryRelease
+		resp, err := c.retry(ctx, func() (resp *github.Response, err error) {
+			releases, resp, err = c.client.Repositories.ListReleases(ctx, params.Owner, params.Repo, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			if release.GetDraft() && release.GetTagName() == params.Tag {
+				return toRelease(release), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
 }
 
 // CreateRelease creates a new release
-func (c *Client) CreateRelease(params CreateReleaseParams) (*github.RepositoryRelease, error) {
+func (c *Client) CreateRelease(params backend.CreateReleaseParams) (*backend.Release, error) {
 	ctx := context.Background()
 
 	githubRelease := &github.RepositoryRelease{
-		TagName: github.String(params.Tag),
-		Name:    github.String(params.Name),
-		Draft:   github.Bool(params.Draft),
+		TagName:    github.String(params.Tag),
+		Name:       github.String(params.Name),
+		Body:       github.String(params.Body),
+		Draft:      github.Bool(params.Draft),
+		Prerelease: github.Bool(params.Prerelease),
+	}
+	if params.MakeLatest != "" {
+		githubRelease.MakeLatest = github.String(params.MakeLatest)
 	}
 
-	created, _, err := c.client.Repositories.CreateRelease(ctx, params.Owner, params.Repo, githubRelease)
-	if err != nil {
+	var release *backend.Release
+	if _, err := c.retryWrite(ctx, func() (*github.Response, error) {
+		created, resp, err := c.client.Repositories.CreateRelease(ctx, params.Owner, params.Repo, githubRelease)
+		if err == nil {
+			release = toRelease(created)
+		}
+		return resp, err
+	}, func() (bool, error) {
+		// GitHub creates another release with the same tag if the failed attempt succeeded
+		existing, err := c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: params.Owner, Repo: params.Repo, Tag: params.Tag})
+		if existing != nil {
+			release = existing
+		}
+		return existing != nil, err
+	}); err != nil {
 		return nil, fmt.Errorf("failed to create release: %w", err)
 	}
 
-	return created, nil
+	return release, nil
 }
 
-// UploadAsset uploads an asset to a release
-func (c *Client) UploadAsset(params UploadAssetParams) error {
+// PublishRelease publishes a draft release
+func (c *Client) PublishRelease(params backend.PublishReleaseParams) (*backend.Release, error) {
 	ctx := context.Background()
 
-	file, err := os.Open(params.Asset.Path)
-	if err != nil {
-		return fmt.Errorf("failed to open asset file %s: %w", params.Asset.Path, err)
+	githubRelease := &github.RepositoryRelease{
+		Draft: github.Bool(false),
+	}
+	if params.MakeLatest != "" {
+		githubRelease.MakeLatest = github.String(params.MakeLatest)
 	}
-	defer func() { _ = file.Close() }()
 
-	opts := &github.UploadOptions{Name: params.Asset.Name}
-	_, _, err = c.client.Repositories.UploadReleaseAsset(ctx, params.Owner, params.Repo, params.ReleaseID, opts, file)
-	if err != nil {
-		return fmt.Errorf("failed to upload asset %s: %w", params.Asset.Name, err)
+	var published *github.RepositoryRelease
+	if _, err := c.retry(ctx, func() (resp *github.Response, err error) {
+		published, resp, err = c.client.Repositories.EditRelease(ctx, params.Owner, params.Repo, params.Release.ID, githubRelease)
+		return resp, err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to publish release: %w", err)
+	}
+
+	return toRelease(published), nil
+}
+
+// DeleteRelease deletes a release
+func (c *Client) DeleteRelease(params backend.DeleteReleaseParams) error {
+	ctx := context.Background()
+
+	if _, err := c.retry(ctx, func() (*github.Response, error) {
+		return c.client.Repositories.DeleteRelease(ctx, params.Owner, params.Repo, params.Release.ID)
+	}); err != nil {
+		return fmt.Errorf("failed to delete release: %w", err)
 	}
 
 	return nil
 }
 
+// UpdateRelease updates the name and body of a release. The name is left as is if empty.
+func (c *Client) UpdateRelease(params backend.UpdateReleaseParams) (*backend.Release, error) {
+	ctx := context.Background()
+
+	githubRelease := &github.RepositoryRelease{
+		Body: github.String(params.Body),
+	}
+	if params.Name != "" {
+		githubRelease.Name = github.String(params.Name)
+	}
+
+	var updated *github.RepositoryRelease
+	if _, err := c.retry(ctx, func() (resp *github.Response, err error) {
+		updated, resp, err = c.client.Repositories.EditRelease(ctx, params.Owner, params.Repo, params.Release.ID, githubRelease)
+		return resp, err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to update release: %w", err)
+	}
+
+	return toRelease(updated), nil
+}
+
+// ListReleaseAssets lists every asset of a release
+func (c *Client) ListReleaseAssets(params backend.ListReleaseAssetsParams) ([]*backend.Asset, error) {
+	ctx := context.Background()
+
+	var assets []*backend.Asset
+	page := 1
+	for {
+		// go-github doesn't decode asset digests, so decode the response ourselves
+		u := fmt.Sprintf("repos/%s/%s/releases/%d/assets?per_page=100&page=%d", params.Owner, params.Repo, params.Release.ID, page)
+		req, err := c.client.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list release assets: %w", err)
+		}
+
+		var list []*releaseAsset
+		resp, err := c.retry(ctx, func() (*github.Response, error) {
+			list = nil
+			return c.client.Do(ctx, req, &list)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list release assets: %w", err)
+		}
+		for _, asset := range list {
+			assets = append(assets, &backend.Asset{
+				ID:     asset.GetID(),
+				Name:   asset.GetName(),
+				Size:   int64(asset.GetSize()),
+				State:  asset.GetState(),
+				Digest: asset.Digest,
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	return assets, nil
+}
+
+// DownloadAsset writes the content of a release asset to w
+func (c *Client) DownloadAsset(params backend.DownloadAssetParams, w io.Writer) error {
+	ctx := context.Background()
+
+	var body io.ReadCloser
+	if _, err := c.retry(ctx, func() (*github.Response, error) {
+		var err error
+		body, _, err = c.client.Repositories.DownloadReleaseAsset(ctx, params.Owner, params.Repo, params.Asset.ID, c.downloadClient)
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) {
+			return &github.Response{Response: errResp.Response}, err
+		}
+		return nil, err
+	}); err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteReleaseAsset deletes an asset of a release
+func (c *Client) DeleteReleaseAsset(params backend.DeleteReleaseAssetParams) error {
+	ctx := context.Background()
+
+	if _, err := c.retry(ctx, func() (*github.Response, error) {
+		return c.client.Repositories.DeleteReleaseAsset(ctx, params.Owner, params.Repo, params.Asset.ID)
+	}); err != nil {
+		return fmt.Errorf("failed to delete release asset: %w", err)
+	}
+
+	return nil
+}
+
+// GetCommitAuthors retrieves the GitHub logins of the authors of commits between base and head, keyed by commit SHA.
+// Commits whose author isn't linked to a GitHub account are omitted.
+func (c *Client) GetCommitAuthors(params GetCommitAuthorsParams) (map[string]string, error) {
+	ctx := context.Background()
+
+	authors := map[string]string{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var comparison *github.CommitsComparison
+		resp, err := c.retry(ctx, func() (resp *github.Response, err error) {
+			comparison, resp, err = c.client.Repositories.CompareCommits(ctx, params.Owner, params.Repo, params.Base, params.Head, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare commits: %w", err)
+		}
+		for _, commit := range comparison.Commits {
+			if login := commit.GetAuthor().GetLogin(); login != "" {
+				authors[commit.GetSHA()] = login
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return authors, nil
+}
+
+// UploadAsset uploads an asset to a release
+func (c *Client) UploadAsset(ctx context.Context, params backend.UploadAssetParams) error {
+	u := fmt.Sprintf("repos/%s/%s/releases/%d/assets?name=%s", params.Owner, params.Repo, params.Release.ID, url.QueryEscape(params.Name))
+	attempt := 0
+	if _, err := c.retry(ctx, func() (*github.Response, error) {
+		attempt++
+		if attempt > 1 {
+			// Remove what a failed attempt may have left behind, which would make the name conflict
+			if err := c.deletePartialAsset(ctx, params); err != nil {
+				return nil, err
+			}
+		}
+
+		// Open the file for every attempt, since the body is closed once the request is sent
+		file, err := os.Open(params.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open asset file %s: %w", params.Path, err)
+		}
+		defer func() { _ = file.Close() }()
+
+		stat, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat asset file %s: %w", params.Path, err)
+		}
+
+		// Stream the file with an explicit length instead of buffering it
+		var body io.Reader = file
+		if params.Progress != nil {
+			body = backend.NewProgressReader(file, stat.Size(), params.Progress)
+		}
+		req, err := c.client.NewUploadRequest(u, body, stat.Size(), backend.MediaType(params.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		return c.client.Do(ctx, req, nil)
+	}); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+
+	return nil
+}
+
+// deletePartialAsset deletes an asset that a failed upload created
+func (c *Client) deletePartialAsset(ctx context.Context, params backend.UploadAssetParams) error {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var assets []*github.ReleaseAsset
+		resp, err := c.retry(ctx, func() (resp *github.Response, err error) {
+			assets, resp, err = c.client.Repositories.ListReleaseAssets(ctx, params.Owner, params.Repo, params.Release.ID, opts)
+			return resp, err
+		})
+		if err != nil {
+			return &noRetryError{err: fmt.Errorf("failed to list release assets: %w", err)}
+		}
+
+		for _, asset := range assets {
+			if asset.GetName() != params.Name {
+				continue
+			}
+			if _, err := c.retry(ctx, func() (*github.Response, error) {
+				return c.client.Repositories.DeleteReleaseAsset(ctx, params.Owner, params.Repo, asset.GetID())
+			}); err != nil {
+				return &noRetryError{err: fmt.Errorf("failed to delete partial asset: %w", err)}
+			}
+			return nil
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
 // UpdateFile creates or updates a file in the repository
-func (c *Client) UpdateFile(params UpdateFileParams) error {
+func (c *Client) UpdateFile(params backend.UpdateFileParams) error {
 	ctx := context.Background()
 
 	// Get SHA of existing file
-	existingFile, _, resp, err := c.client.Repositories.GetContents(ctx, params.Owner, params.Repo, params.Path, nil)
-
-	var sha *string
-	if err == nil && existingFile != nil {
-		sha = existingFile.SHA
-	} else if resp != nil && resp.StatusCode != http.StatusNotFound {
-		return fmt.Errorf("failed to check existing file: %w", err)
+	existingFile, err := c.getFile(ctx, params)
+	if err != nil {
+		return err
 	}
 
 	// Create or update file
 	opts := &github.RepositoryContentFileOptions{
 		Message: github.String(params.CommitMessage),
 		Content: []byte(params.Content),
-		SHA:     sha,
 	}
-	_, _, err = c.client.Repositories.CreateFile(ctx, params.Owner, params.Repo, params.Path, opts)
-	if err != nil {
+	if existingFile != nil {
+		opts.SHA = existingFile.SHA
+	}
+	if _, err := c.retryWrite(ctx, func() (resp *github.Response, err error) {
+		_, resp, err = c.client.Repositories.CreateFile(ctx, params.Owner, params.Repo, params.Path, opts)
+		return resp, err
+	}, func() (bool, error) {
+		// Re-read the file, since a commit of the failed attempt makes the SHA stale
+		existingFile, err := c.getFile(ctx, params)
+		if err != nil {
+			return false, err
+		}
+		if existingFile == nil {
+			opts.SHA = nil
+			return false, nil
+		}
+		content, err := existingFile.GetContent()
+		if err != nil {
+			return false, fmt.Errorf("failed to decode existing file: %w", err)
+		}
+		opts.SHA = existingFile.SHA
+		return content == params.Content, nil
+	}); err != nil {
 		return fmt.Errorf("failed to update file: %w", err)
 	}
 
 	return nil
 }
+
+// getFile retrieves the file to update. nil is returned if it doesn't exist yet.
+func (c *Client) getFile(ctx context.Context, params backend.UpdateFileParams) (*github.RepositoryContent, error) {
+	var file *github.RepositoryContent
+	resp, err := c.retry(ctx, func() (resp *github.Response, err error) {
+		file, _, resp, err = c.client.Repositories.GetContents(ctx, params.Owner, params.Repo, params.Path, nil)
+		return resp, err
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check existing file: %w", err)
+	}
+	return file, nil
+}
+
+// toRelease converts a GitHub release
+func toRelease(release *github.RepositoryRelease) *backend.Release {
+	return &backend.Release{
+		ID:    release.GetID(),
+		Tag:   release.GetTagName(),
+		Name:  release.GetName(),
+		Body:  release.GetBody(),
+		Draft: release.GetDraft(),
+	}
+}
@@ -0,0 +1,554 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient creates a client talking to a fake GitHub server and records the waits between retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *[]time.Duration, *bytes.Buffer) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := New("token", Options{Retry: RetryOptions{Attempts: 3, InitialInterval: time.Millisecond, MaxInterval: 10 * time.Millisecond}, Verbose: true})
+	require.NoError(t, err)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	c.client.BaseURL = baseURL
+	c.client.UploadURL = baseURL
+
+	var waits []time.Duration
+	var log bytes.Buffer
+	c.log = &log
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return c, &waits, &log
+}
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name              string
+		options           Options
+		expectedBaseURL   string
+		expectedUploadURL string
+	}{
+		{
+			name:              "github.com",
+			expectedBaseURL:   "https://api.github.com/",
+			expectedUploadURL: "https://uploads.github.com/",
+		},
+		{
+			name:              "GITHUB_API_URL of github.com",
+			options:           Options{APIURL: "https://api.github.com"},
+			expectedBaseURL:   "https://api.github.com/",
+			expectedUploadURL: "https://uploads.github.com/",
+		},
+		{
+			name:              "enterprise server",
+			options:           Options{APIURL: "https://github.example.com/api/v3/"},
+			expectedBaseURL:   "https://github.example.com/api/v3/",
+			expectedUploadURL: "https://github.example.com/api/uploads/",
+		},
+		{
+			name:              "enterprise server with upload url",
+			options:           Options{APIURL: "https://github.example.com", UploadURL: "https://uploads.example.com/api/uploads"},
+			expectedBaseURL:   "https://github.example.com/api/v3/",
+			expectedUploadURL: "https://uploads.example.com/api/uploads/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New("token", tt.options)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBaseURL, c.client.BaseURL.String())
+			assert.Equal(t, tt.expectedUploadURL, c.client.UploadURL.String())
+		})
+	}
+}
+
+func Test_SkipTLSVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, skip := range []bool{false, true} {
+		c, err := New("token", Options{APIURL: server.URL + "/api/v3/", SkipTLSVerify: skip, Retry: RetryOptions{Attempts: 1}})
+		require.NoError(t, err)
+
+		_, err = c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}})
+		if skip {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func Test_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		failure      func(w http.ResponseWriter)
+		wantAttempts int32
+		wantErr      bool
+		realSleep    bool // go-github refuses requests until the rate limit resets
+		checkWaits   func(t *testing.T, waits []time.Duration)
+	}{
+		{
+			name:     "retries server errors",
+			failures: 2,
+			failure: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantAttempts: 3,
+			checkWaits: func(t *testing.T, waits []time.Duration) {
+				require.Len(t, waits, 2)
+				for _, wait := range waits {
+					assert.LessOrEqual(t, wait, 10*time.Millisecond)
+				}
+			},
+		},
+		{
+			name:     "honors Retry-After",
+			failures: 1,
+			failure: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantAttempts: 2,
+			checkWaits: func(t *testing.T, waits []time.Duration) {
+				assert.Equal(t, []time.Duration{7 * time.Second}, waits)
+			},
+		},
+		{
+			name:     "honors X-RateLimit-Reset",
+			failures: 1,
+			failure: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Limit", "5000")
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+			},
+			wantAttempts: 2,
+			realSleep:    true,
+			checkWaits: func(t *testing.T, waits []time.Duration) {
+				require.Len(t, waits, 1)
+				assert.Greater(t, waits[0], 500*time.Millisecond)
+				assert.LessOrEqual(t, waits[0], 2*time.Second)
+			},
+		},
+		{
+			name:     "gives up after the last attempt",
+			failures: 5,
+			failure: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:     "doesn't retry client errors",
+			failures: 1,
+			failure: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message": "Validation Failed"}`))
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c, waits, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.failures {
+					tt.failure(w)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id": 1, "tag_name": "v1.0.0"}`))
+			})
+
+			if tt.realSleep {
+				record := c.sleep
+				c.sleep = func(ctx context.Context, d time.Duration) error {
+					_ = record(ctx, d)
+					return sleepContext(ctx, d)
+				}
+			}
+
+			release, err := c.PublishRelease(backend.PublishReleaseParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}})
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), release.ID)
+			assert.Equal(t, "v1.0.0", release.Tag)
+			if tt.checkWaits != nil {
+				tt.checkWaits(t, *waits)
+			}
+		})
+	}
+}
+
+func Test_CreateRelease_Retry(t *testing.T) {
+	tests := []struct {
+		name          string
+		failure       func(w http.ResponseWriter)
+		created       bool // whether the failed attempt created the release anyway
+		wantCreates   int
+		wantLookups   int
+		wantReleaseID int64
+	}{
+		{
+			name: "failed attempt created the release",
+			failure: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			created:       true,
+			wantCreates:   1,
+			wantLookups:   1,
+			wantReleaseID: 1,
+		},
+		{
+			name: "failed attempt didn't create the release",
+			failure: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantCreates:   2,
+			wantLookups:   1,
+			wantReleaseID: 2,
+		},
+		{
+			name: "rate limited attempt is retried as is",
+			failure: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantCreates:   2,
+			wantReleaseID: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var creates, lookups int
+			c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost:
+					creates++
+					if creates == 1 {
+						tt.failure(w)
+						return
+					}
+					w.WriteHeader(http.StatusCreated)
+					_, _ = fmt.Fprintf(w, `{"id": %d, "tag_name": "v1.0.0", "draft": true}`, creates)
+				case r.URL.Path == "/repos/o/r/releases/tags/v1.0.0":
+					lookups++
+					w.WriteHeader(http.StatusNotFound)
+				case r.URL.Path == "/repos/o/r/releases":
+					// Drafts are only found in the release list
+					if tt.created {
+						_, _ = w.Write([]byte(`[{"id": 1, "tag_name": "v1.0.0", "draft": true}]`))
+						return
+					}
+					_, _ = w.Write([]byte(`[]`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+
+			release, err := c.CreateRelease(backend.CreateReleaseParams{Owner: "o", Repo: "r", Tag: "v1.0.0", Draft: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantReleaseID, release.ID)
+			assert.Equal(t, tt.wantCreates, creates)
+			assert.Equal(t, tt.wantLookups, lookups)
+		})
+	}
+}
+
+func Test_UpdateFile_Retry(t *testing.T) {
+	encode := func(content string) string {
+		return base64.StdEncoding.EncodeToString([]byte(content))
+	}
+
+	tests := []struct {
+		name        string
+		committed   bool // whether the failed attempt committed the file anyway
+		wantPuts    int
+		wantLastSHA string
+	}{
+		{
+			name:      "failed attempt committed the file",
+			committed: true,
+			wantPuts:  1,
+		},
+		{
+			name:        "failed attempt didn't commit the file",
+			wantPuts:    2,
+			wantLastSHA: "old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "old content"
+			sha := "old"
+			var puts int
+			var lastSHA string
+			c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					_, _ = fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "sha": %q, "content": %q}`, sha, encode(content))
+				case http.MethodPut:
+					puts++
+					var body struct {
+						Content string `json:"content"`
+						SHA     string `json:"sha"`
+					}
+					if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&body)) {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					lastSHA = body.SHA
+					if body.SHA != sha {
+						w.WriteHeader(http.StatusConflict)
+						return
+					}
+					if puts == 1 && tt.committed {
+						content, sha = "new content", "new"
+					}
+					if puts == 1 {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					_, _ = w.Write([]byte(`{}`))
+				}
+			})
+
+			err := c.UpdateFile(backend.UpdateFileParams{Owner: "o", Repo: "r", Path: "Formula/app.rb", Content: "new content", CommitMessage: "Update app"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPuts, puts)
+			if tt.wantLastSHA != "" {
+				assert.Equal(t, tt.wantLastSHA, lastSHA)
+			}
+		})
+	}
+}
+
+func Test_UploadAsset_DeletePartialAsset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("gorocket"), 0644))
+
+	var server string
+	var uploads, listings int
+	var deleted []string
+	c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			uploads++
+			if uploads == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 3, "name": "app.tar.gz"}`))
+		case http.MethodGet:
+			// The partial asset is on the second page and listing fails once
+			listings++
+			switch {
+			case listings == 1:
+				w.WriteHeader(http.StatusBadGateway)
+			case r.URL.Query().Get("page") == "":
+				w.Header().Set("Link", fmt.Sprintf(`<%srepos/o/r/releases/1/assets?per_page=100&page=2>; rel="next"`, server))
+				_, _ = w.Write([]byte(`[{"id": 1, "name": "checksums.txt"}]`))
+			default:
+				_, _ = w.Write([]byte(`[{"id": 2, "name": "app.tar.gz"}]`))
+			}
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	server = c.client.BaseURL.String()
+
+	err := c.UploadAsset(context.Background(), backend.UploadAssetParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}, Name: "app.tar.gz", Path: path})
+	require.NoError(t, err)
+	assert.Equal(t, 2, uploads)
+	assert.Equal(t, 3, listings)
+	assert.Equal(t, []string{"/repos/o/r/releases/assets/2"}, deleted)
+}
+
+func Test_UploadAsset_Retry(t *testing.T) {
+	content := bytes.Repeat([]byte("gorocket"), 1024)
+	path := filepath.Join(t.TempDir(), "app.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+
+	var uploads [][]byte
+	c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			uploads = append(uploads, body)
+			if len(uploads) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 2, "name": "app.tar.gz"}`))
+		case http.MethodGet:
+			// The failed attempt left nothing behind
+			_, _ = w.Write([]byte(`[]`))
+		}
+	})
+
+	err := c.UploadAsset(context.Background(), backend.UploadAssetParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}, Name: "app.tar.gz", Path: path})
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	assert.Equal(t, content, uploads[0])
+	assert.Equal(t, content, uploads[1])
+}
+
+func Test_UploadAsset(t *testing.T) {
+	tests := []struct {
+		name              string
+		asset             string
+		expectedMediaType string
+	}{
+		{name: "gzip archive", asset: "app_linux_amd64.tar.gz", expectedMediaType: "application/gzip"},
+		{name: "zip archive", asset: "app_windows_amd64.zip", expectedMediaType: "application/zip"},
+		{name: "zstd archive", asset: "app_linux_arm64.tar.zst", expectedMediaType: "application/zstd"},
+		{name: "unknown extension", asset: "app_linux_amd64.tar.gz.sha256", expectedMediaType: "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := bytes.Repeat([]byte("gorocket"), 64*1024)
+			path := filepath.Join(t.TempDir(), tt.asset)
+			require.NoError(t, os.WriteFile(path, content, 0644))
+
+			var req *http.Request
+			var body []byte
+			c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id": 2}`))
+			})
+
+			var progress []int64
+			err := c.UploadAsset(context.Background(), backend.UploadAssetParams{
+				Owner:   "o",
+				Repo:    "r",
+				Release: &backend.Release{ID: 1},
+				Name:    tt.asset,
+				Path:    path,
+				Progress: func(written, total int64) {
+					assert.Equal(t, int64(len(content)), total)
+					progress = append(progress, written)
+				},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, "/repos/o/r/releases/1/assets", req.URL.Path)
+			assert.Equal(t, tt.asset, req.URL.Query().Get("name"))
+			assert.Equal(t, int64(len(content)), req.ContentLength)
+			assert.Equal(t, tt.expectedMediaType, req.Header.Get("Content-Type"))
+			assert.Equal(t, content, body)
+
+			require.NotEmpty(t, progress)
+			assert.Equal(t, int64(0), progress[0])
+			assert.Equal(t, int64(len(content)), progress[len(progress)-1])
+		})
+	}
+}
+
+func Test_ListReleaseAssets(t *testing.T) {
+	var server string
+	c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<%srepos/o/r/releases/1/assets?per_page=100&page=2>; rel="next"`, server))
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "a.tar.gz", "size": 3, "state": "uploaded", "digest": "sha256:abc"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id": 2, "name": "checksums.txt", "size": 5, "state": "uploaded"}]`))
+	})
+	server = c.client.BaseURL.String()
+
+	assets, err := c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}})
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assert.Equal(t, &backend.Asset{ID: 1, Name: "a.tar.gz", Size: 3, State: "uploaded", Digest: "sha256:abc"}, assets[0])
+	assert.Equal(t, &backend.Asset{ID: 2, Name: "checksums.txt", Size: 5, State: "uploaded"}, assets[1])
+}
+
+func Test_DownloadAsset(t *testing.T) {
+	content := bytes.Repeat([]byte("gorocket"), 1024)
+
+	var attempts atomic.Int32
+	c, _, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/assets/1":
+			assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			// Assets are served from another host
+			http.Redirect(w, r, "/storage/a.tar.gz", http.StatusFound)
+		case "/storage/a.tar.gz":
+			assert.Empty(t, r.Header.Get("Authorization"))
+			_, _ = w.Write(content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DownloadAsset(backend.DownloadAssetParams{Owner: "o", Repo: "r", Asset: &backend.Asset{ID: 1}}, &buf))
+	assert.Equal(t, content, buf.Bytes())
+	assert.Equal(t, int32(2), attempts.Load())
+
+	err := c.DownloadAsset(backend.DownloadAssetParams{Owner: "o", Repo: "r", Asset: &backend.Asset{ID: 2}}, &buf)
+	assert.Error(t, err)
+}
+
+func Test_VerboseRateLimit(t *testing.T) {
+	c, _, log := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		_, _ = w.Write([]byte(`[]`))
+	})
+
+	_, err := c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "o", Repo: "r", Release: &backend.Release{ID: 1}})
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), "GitHub API rate limit: 4999/5000 remaining")
+}
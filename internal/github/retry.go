@@ -0,0 +1,210 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// RetryOptions controls how failed API calls are retried
+type RetryOptions struct {
+	Attempts        int           // Total number of attempts (defaults to 5)
+	InitialInterval time.Duration // Backoff before the first retry (defaults to 1s)
+	MaxInterval     time.Duration // Upper bound of the backoff (defaults to 30s)
+	MaxWait         time.Duration // Longest wait requested by the server that is honored (defaults to 10m)
+}
+
+// withDefaults fills in unset retry options
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.Attempts <= 0 {
+		o.Attempts = 5
+	}
+	if o.InitialInterval <= 0 {
+		o.InitialInterval = time.Second
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = 30 * time.Second
+	}
+	if o.MaxWait <= 0 {
+		o.MaxWait = 10 * time.Minute
+	}
+	return o
+}
+
+// retry calls fn until it succeeds, fails permanently or runs out of attempts.
+// fn must be safe to call again, e.g. rewind request bodies.
+func (c *Client) retry(ctx context.Context, fn func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		c.logRate(resp)
+		if err == nil {
+			return resp, nil
+		}
+
+		// Give up on permanent errors and after the last attempt
+		wait, ok := c.retryDelay(resp, err, attempt)
+		if !ok || attempt >= c.retryOptions.Attempts {
+			return resp, err
+		}
+		if wait > c.retryOptions.MaxWait {
+			return resp, fmt.Errorf("%w (retry would have to wait %s)", err, wait.Round(time.Second))
+		}
+
+		_, _ = fmt.Fprintf(c.log, "GitHub API request failed, retrying in %s (attempt %d/%d): %v\n", wait.Round(time.Millisecond), attempt+1, c.retryOptions.Attempts, err)
+		if err := c.sleep(ctx, wait); err != nil {
+			return resp, err
+		}
+	}
+}
+
+// retryWrite calls fn like retry, for requests that aren't idempotent such as creating a release.
+// Unless a failed attempt provably never reached the server, applied is called before the next attempt
+// to find out whether the failed attempt took effect anyway, in which case no further attempt is made.
+func (c *Client) retryWrite(ctx context.Context, fn func() (*github.Response, error), applied func() (bool, error)) (*github.Response, error) {
+	mayHaveApplied := false
+	return c.retry(ctx, func() (*github.Response, error) {
+		if mayHaveApplied {
+			ok, err := applied()
+			if err != nil {
+				return nil, &noRetryError{err: err}
+			}
+			if ok {
+				return nil, nil
+			}
+		}
+
+		resp, err := fn()
+		mayHaveApplied = err != nil && reachedServer(resp, err)
+		return resp, err
+	})
+}
+
+// reachedServer reports whether a failed request may have been processed by the server.
+// Rate limited requests and connections that were never established are known to have no effect.
+func reachedServer(resp *github.Response, err error) bool {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return false
+	}
+
+	if resp == nil {
+		var opErr *net.OpError
+		return !errors.As(err, &opErr) || opErr.Op != "dial"
+	}
+	return resp.StatusCode != http.StatusTooManyRequests
+}
+
+// noRetryError marks an error that must not be retried
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// retryDelay determines whether a failed call should be retried and how long to wait before that
+func (c *Client) retryDelay(resp *github.Response, err error, attempt int) (time.Duration, bool) {
+	var noRetryErr *noRetryError
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &noRetryErr) {
+		return 0, false
+	}
+
+	// Primary rate limit: wait until the limit resets
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return time.Until(rateLimitErr.Rate.Reset.Time) + time.Second, true
+	}
+
+	// Secondary rate limit: wait as instructed
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return c.backoff(attempt), true
+	}
+
+	// Network errors
+	if resp == nil {
+		return c.backoff(attempt), true
+	}
+
+	// Honor headers of the response
+	if wait, ok := headerDelay(resp.Response); ok {
+		return wait, true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return c.backoff(attempt), true
+	default:
+		return 0, false
+	}
+}
+
+// backoff returns an exponential backoff with full jitter for the given attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	interval := c.retryOptions.InitialInterval << (attempt - 1)
+	if interval <= 0 || interval > c.retryOptions.MaxInterval {
+		interval = c.retryOptions.MaxInterval
+	}
+	return interval/2 + rand.N(interval/2+1)
+}
+
+// headerDelay returns the wait requested by Retry-After, or by X-RateLimit-Reset once the rate limit is exhausted
+func headerDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if sec, err := strconv.Atoi(value); err == nil {
+			return time.Duration(sec) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)) + time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// logRate prints the rate limit state in verbose mode
+func (c *Client) logRate(resp *github.Response) {
+	if !c.verbose || resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(c.log, "GitHub API rate limit: %d/%d remaining, resets at %s\n",
+		resp.Rate.Remaining, resp.Rate.Limit, resp.Rate.Reset.Local().Format(time.TimeOnly))
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,484 @@
+package gitlab
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+)
+
+// DefaultAPIURL is the API URL of gitlab.com
+const DefaultAPIURL = "https://gitlab.com/api/v4/"
+
+// draftReleaseDate is the release date of releases kept as drafts.
+// GitLab has no drafts, so they are created as upcoming releases, which are listed publicly.
+var draftReleaseDate = time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Client is a GitLab API client using the Releases and Generic Packages APIs.
+// Assets are uploaded to a generic package named after the project, versioned by the tag, and linked from the release.
+type Client struct {
+	api *backend.APIClient
+}
+
+// Options configures a Client
+type Options struct {
+	APIURL        string // e.g. https://gitlab.example.com/api/v4/ (defaults to gitlab.com)
+	SkipTLSVerify bool   // Don't verify TLS certificates, e.g. of self-signed instances
+}
+
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
+
+// release is a release of the Releases API
+type release struct {
+	TagName         string `json:"tag_name"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	UpcomingRelease bool   `json:"upcoming_release"`
+	Assets          struct {
+		Links []*releaseLink `json:"links"`
+	} `json:"assets"`
+}
+
+// releaseLink is an asset link of a release
+type releaseLink struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// packageInfo is a package of the Packages API
+type packageInfo struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// packageFile is a file of a package
+type packageFile struct {
+	ID         int64  `json:"id"`
+	FileName   string `json:"file_name"`
+	Size       int64  `json:"size"`
+	FileSHA256 string `json:"file_sha256"`
+}
+
+// New creates a new GitLab client
+func New(token string, options Options) (*Client, error) {
+	api, err := backend.NewAPIClient(backend.APIOptions{
+		Name:          "GitLab",
+		URL:           cmp.Or(options.APIURL, DefaultAPIURL),
+		AuthHeader:    "PRIVATE-TOKEN",
+		Auth:          token,
+		SkipTLSVerify: options.SkipTLSVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{api: api}, nil
+}
+
+// GetReleaseByTag retrieves a release by tag name, including upcoming releases.
+// nil is returned if there is no such release.
+func (c *Client) GetReleaseByTag(params backend.GetReleaseByTagParams) (*backend.Release, error) {
+	var r release
+	if err := c.api.Do(http.MethodGet, releasePath(params.Owner, params.Repo, params.Tag), nil, &r); err != nil {
+		if backend.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// CreateRelease creates a new release.
+// Drafts are pending: their assets are uploaded to the package and the release is created by PublishRelease,
+// since GitLab would list a release with missing assets publicly.
+// GitLab has no prereleases and determines the latest release by date, so Prerelease and MakeLatest are ignored.
+func (c *Client) CreateRelease(params backend.CreateReleaseParams) (*backend.Release, error) {
+	if params.Draft {
+		return &backend.Release{Tag: params.Tag, Name: params.Name, Body: params.Body, Draft: true, Pending: true}, nil
+	}
+
+	body := map[string]any{
+		"tag_name":    params.Tag,
+		"name":        params.Name,
+		"description": params.Body,
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPost, projectPath(params.Owner, params.Repo)+"/releases", body, &r); err != nil {
+		return nil, fmt.Errorf("failed to create release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// UpdateRelease updates the name and description of a release. The name is left as is if empty.
+func (c *Client) UpdateRelease(params backend.UpdateReleaseParams) (*backend.Release, error) {
+	body := map[string]any{
+		"description": params.Body,
+	}
+	if params.Name != "" {
+		body["name"] = params.Name
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPut, releasePath(params.Owner, params.Repo, params.Release.Tag), body, &r); err != nil {
+		return nil, fmt.Errorf("failed to update release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// PublishRelease releases an upcoming release now, or creates a pending release linking every file of its package.
+// With params.Draft a pending release is created as an upcoming release.
+func (c *Client) PublishRelease(params backend.PublishReleaseParams) (*backend.Release, error) {
+	if params.Release.Pending {
+		return c.createPendingRelease(params)
+	}
+
+	body := map[string]any{
+		"released_at": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPut, releasePath(params.Owner, params.Repo, params.Release.Tag), body, &r); err != nil {
+		return nil, fmt.Errorf("failed to publish release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// createPendingRelease creates a pending release with links to the files of its package
+func (c *Client) createPendingRelease(params backend.PublishReleaseParams) (*backend.Release, error) {
+	files, err := c.packageFiles(params.Owner, params.Repo, params.Release.Tag)
+	if err != nil {
+		return nil, err
+	}
+	names := slices.Sorted(maps.Keys(files))
+
+	var links []map[string]any
+	for _, name := range names {
+		links = append(links, assetLink(c.api.URL+packageFilePath(params.Owner, params.Repo, params.Release.Tag, name), name))
+	}
+	body := map[string]any{
+		"tag_name":    params.Release.Tag,
+		"name":        params.Release.Name,
+		"description": params.Release.Body,
+		"assets":      map[string]any{"links": links},
+	}
+	if params.Draft {
+		body["released_at"] = draftReleaseDate.Format(time.RFC3339)
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodPost, projectPath(params.Owner, params.Repo)+"/releases", body, &r); err != nil {
+		return nil, fmt.Errorf("failed to create release: %w", err)
+	}
+
+	return toRelease(&r), nil
+}
+
+// DeleteRelease deletes a release and the package holding its assets. The tag is kept.
+// A pending release may not have been created yet.
+func (c *Client) DeleteRelease(params backend.DeleteReleaseParams) error {
+	if err := c.api.Do(http.MethodDelete, releasePath(params.Owner, params.Repo, params.Release.Tag), nil, nil); err != nil && !(params.Release.Pending && backend.IsNotFound(err)) {
+		return fmt.Errorf("failed to delete release: %w", err)
+	}
+
+	pkg, err := c.findPackage(params.Owner, params.Repo, params.Release.Tag)
+	if err != nil {
+		return err
+	}
+	if pkg != nil {
+		if err := c.api.Do(http.MethodDelete, fmt.Sprintf("%s/packages/%d", projectPath(params.Owner, params.Repo), pkg.ID), nil, nil); err != nil {
+			return fmt.Errorf("failed to delete package: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListReleaseAssets lists the asset links of a release with the size and digest of the linked package files.
+// The assets of a pending release are the files of its package.
+func (c *Client) ListReleaseAssets(params backend.ListReleaseAssetsParams) ([]*backend.Asset, error) {
+	if params.Release.Pending {
+		files, err := c.packageFiles(params.Owner, params.Repo, params.Release.Tag)
+		if err != nil {
+			return nil, err
+		}
+
+		assets := make([]*backend.Asset, 0, len(files))
+		for _, name := range slices.Sorted(maps.Keys(files)) {
+			file := files[name]
+			asset := &backend.Asset{ID: file.ID, Name: name, Size: file.Size, State: "uploaded"}
+			if file.FileSHA256 != "" {
+				asset.Digest = "sha256:" + file.FileSHA256
+			}
+			assets = append(assets, asset)
+		}
+		return assets, nil
+	}
+
+	var r release
+	if err := c.api.Do(http.MethodGet, releasePath(params.Owner, params.Repo, params.Release.Tag), nil, &r); err != nil {
+		return nil, fmt.Errorf("failed to list release assets: %w", err)
+	}
+
+	files, err := c.packageFiles(params.Owner, params.Repo, params.Release.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make([]*backend.Asset, 0, len(r.Assets.Links))
+	for _, link := range r.Assets.Links {
+		asset := &backend.Asset{ID: link.ID, Name: link.Name, State: "missing"}
+		if file, ok := files[link.Name]; ok {
+			asset.Size = file.Size
+			asset.State = "uploaded"
+			if file.FileSHA256 != "" {
+				asset.Digest = "sha256:" + file.FileSHA256
+			}
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// UploadAsset uploads a file to the generic package of the release and links it from the release unless it is pending
+func (c *Client) UploadAsset(ctx context.Context, params backend.UploadAssetParams) error {
+	file, err := os.Open(params.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open asset file %s: %w", params.Path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat asset file %s: %w", params.Path, err)
+	}
+
+	// Stream the file with an explicit length instead of buffering it
+	var body io.Reader = file
+	if params.Progress != nil {
+		body = backend.NewProgressReader(file, stat.Size(), params.Progress)
+	}
+	fileURL := c.api.URL + packageFilePath(params.Owner, params.Repo, params.Release.Tag, params.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fileURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+	req.ContentLength = stat.Size()
+	req.Header.Set("Content-Type", backend.MediaType(params.Name))
+	if _, err := c.api.Send(req, nil); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", params.Name, err)
+	}
+
+	// Link the package file from the release
+	if params.Release.Pending {
+		return nil
+	}
+	if err := c.api.Do(http.MethodPost, releasePath(params.Owner, params.Repo, params.Release.Tag)+"/assets/links", assetLink(fileURL, params.Name), nil); err != nil {
+		return fmt.Errorf("failed to link asset %s: %w", params.Name, err)
+	}
+
+	return nil
+}
+
+// assetLink returns the release link of a package file
+func assetLink(fileURL, name string) map[string]any {
+	return map[string]any{
+		"name":              name,
+		"url":               fileURL,
+		"direct_asset_path": "/" + name,
+		"link_type":         "package",
+	}
+}
+
+// DeleteReleaseAsset deletes an asset link of a release and the package files it points to
+func (c *Client) DeleteReleaseAsset(params backend.DeleteReleaseAssetParams) error {
+	if !params.Release.Pending {
+		path := fmt.Sprintf("%s/assets/links/%d", releasePath(params.Owner, params.Repo, params.Release.Tag), params.Asset.ID)
+		if err := c.api.Do(http.MethodDelete, path, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete release asset: %w", err)
+		}
+	}
+
+	// Remove the package files too, since duplicates may be rejected on upload
+	pkg, err := c.findPackage(params.Owner, params.Repo, params.Release.Tag)
+	if err != nil || pkg == nil {
+		return err
+	}
+	files, err := c.listPackageFiles(params.Owner, params.Repo, pkg.ID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.FileName != params.Asset.Name {
+			continue
+		}
+		path := fmt.Sprintf("%s/packages/%d/package_files/%d", projectPath(params.Owner, params.Repo), pkg.ID, file.ID)
+		if err := c.api.Do(http.MethodDelete, path, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete package file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DownloadAsset writes the content of a release asset to w
+func (c *Client) DownloadAsset(params backend.DownloadAssetParams, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, c.api.URL+packageFilePath(params.Owner, params.Repo, params.Release.Tag, params.Asset.Name), nil)
+	if err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+	if _, err := c.api.Send(req, w); err != nil {
+		return fmt.Errorf("failed to download release asset: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateFile creates or updates a file on the default branch of the repository
+func (c *Client) UpdateFile(params backend.UpdateFileParams) error {
+	// Get default branch
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.api.Do(http.MethodGet, projectPath(params.Owner, params.Repo), nil, &project); err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	// Check existing file
+	path := projectPath(params.Owner, params.Repo) + "/repository/files/" + url.PathEscape(params.Path)
+	method := http.MethodPut
+	if err := c.api.Do(http.MethodGet, path+"?ref="+url.QueryEscape(project.DefaultBranch), nil, nil); err != nil {
+		if !backend.IsNotFound(err) {
+			return fmt.Errorf("failed to check existing file: %w", err)
+		}
+		method = http.MethodPost
+	}
+
+	// Create or update file
+	body := map[string]any{
+		"branch":         project.DefaultBranch,
+		"content":        params.Content,
+		"commit_message": params.CommitMessage,
+	}
+	if err := c.api.Do(method, path, body, nil); err != nil {
+		return fmt.Errorf("failed to update file: %w", err)
+	}
+
+	return nil
+}
+
+// findPackage returns the generic package holding the assets of a release, or nil if there is none
+func (c *Client) findPackage(owner, repo, tag string) (*packageInfo, error) {
+	query := url.Values{
+		"package_type": {"generic"},
+		"package_name": {repo},
+		"per_page":     {"100"},
+	}
+	for page := 1; page != 0; {
+		query.Set("page", strconv.Itoa(page))
+
+		var packages []*packageInfo
+		next, err := c.list(projectPath(owner, repo)+"/packages?"+query.Encode(), &packages)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages: %w", err)
+		}
+		for _, pkg := range packages {
+			if pkg.Name == repo && pkg.Version == tag {
+				return pkg, nil
+			}
+		}
+		page = next
+	}
+
+	return nil, nil
+}
+
+// listPackageFiles lists every file of a package
+func (c *Client) listPackageFiles(owner, repo string, packageID int64) ([]*packageFile, error) {
+	var files []*packageFile
+	for page := 1; page != 0; {
+		var list []*packageFile
+		next, err := c.list(fmt.Sprintf("%s/packages/%d/package_files?per_page=100&page=%d", projectPath(owner, repo), packageID, page), &list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list package files: %w", err)
+		}
+		files = append(files, list...)
+		page = next
+	}
+
+	return files, nil
+}
+
+// packageFiles returns the latest package file of each name for a release
+func (c *Client) packageFiles(owner, repo, tag string) (map[string]*packageFile, error) {
+	files := map[string]*packageFile{}
+
+	pkg, err := c.findPackage(owner, repo, tag)
+	if err != nil || pkg == nil {
+		return files, err
+	}
+	list, err := c.listPackageFiles(owner, repo, pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range list {
+		if existing, ok := files[file.FileName]; !ok || file.ID > existing.ID {
+			files[file.FileName] = file
+		}
+	}
+
+	return files, nil
+}
+
+// list fetches a page of a list and returns the number of the next page, or 0 on the last page
+func (c *Client) list(path string, v any) (int, error) {
+	resp, err := c.api.DoResponse(http.MethodGet, path, nil, v)
+	if err != nil {
+		return 0, err
+	}
+
+	next, _ := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	return next, nil
+}
+
+// projectPath returns the API path of a project, which is addressed by its URL-encoded full path
+func projectPath(owner, repo string) string {
+	return "projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+// releasePath returns the API path of a release
+func releasePath(owner, repo, tag string) string {
+	return projectPath(owner, repo) + "/releases/" + url.PathEscape(tag)
+}
+
+// packageFilePath returns the API path of a file of the generic package of a release
+func packageFilePath(owner, repo, tag, name string) string {
+	return fmt.Sprintf("%s/packages/generic/%s/%s/%s", projectPath(owner, repo), url.PathEscape(repo), url.PathEscape(tag), url.PathEscape(name))
+}
+
+// toRelease converts a GitLab release
+func toRelease(r *release) *backend.Release {
+	return &backend.Release{
+		Tag:   r.TagName,
+		Name:  r.Name,
+		Body:  r.Description,
+		Draft: r.UpcomingRelease,
+	}
+}
@@ -0,0 +1,453 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+	"github.com/koki-develop/gorocket/internal/backend/backendtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitLab is an in-memory stand-in for the GitLab Releases, Packages and Repository Files APIs
+type fakeGitLab struct {
+	*backendtest.Server
+
+	releases map[string]map[string]any // by tag
+	links    map[string][]*releaseLink // by tag
+	files    []*fakePackageFile
+	repo     map[string]string // repository files by path
+	commits  []string          // commit messages
+	nextID   int64
+}
+
+type fakePackageFile struct {
+	packageFile
+	version     string
+	content     []byte
+	contentType string
+}
+
+var (
+	releasePattern     = regexp.MustCompile(`^/api/v4/projects/group%2Fsub%2Fapp/releases/([^/]+)$`)
+	linksPattern       = regexp.MustCompile(`^/api/v4/projects/group%2Fsub%2Fapp/releases/([^/]+)/assets/links(?:/(\d+))?$`)
+	genericPattern     = regexp.MustCompile(`^/api/v4/projects/group%2Fsub%2Fapp/packages/generic/app/([^/]+)/([^/]+)$`)
+	packageFilePattern = regexp.MustCompile(`^/api/v4/projects/group%2Fsub%2Fapp/packages/(\d+)/package_files(?:/(\d+))?$`)
+	packagePattern     = regexp.MustCompile(`^/api/v4/projects/group%2Fsub%2Fapp/packages/(\d+)$`)
+	repoFilePattern    = regexp.MustCompile(`^/api/v4/projects/group%2Ftap/repository/files/([^/]+)$`)
+)
+
+func newFakeGitLab(t *testing.T) (*fakeGitLab, *Client) {
+	t.Helper()
+
+	f := &fakeGitLab{
+		releases: map[string]map[string]any{},
+		links:    map[string][]*releaseLink{},
+		repo:     map[string]string{},
+	}
+	f.Server = backendtest.NewServer(t, f.handle)
+
+	c, err := New("token", Options{APIURL: f.URL + "/api/v4"})
+	require.NoError(t, err)
+	return f, c
+}
+
+func (f *fakeGitLab) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		f.Reply(w, http.StatusUnauthorized, map[string]any{"message": "401 Unauthorized"})
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	var m []string
+	match := func(pattern *regexp.Regexp) bool {
+		m = pattern.FindStringSubmatch(path)
+		return m != nil
+	}
+
+	switch {
+	case r.Method == http.MethodPost && path == "/api/v4/projects/group%2Fsub%2Fapp/releases":
+		var body map[string]any
+		if !f.Decode(w, r, &body) {
+			return
+		}
+		tag := body["tag_name"].(string)
+		if _, ok := f.releases[tag]; ok {
+			f.Reply(w, http.StatusConflict, map[string]any{"message": "Release already exists"})
+			return
+		}
+		f.releases[tag] = body
+
+		// Links can be created with the release
+		if assets, ok := body["assets"].(map[string]any); ok {
+			links, _ := assets["links"].([]any)
+			for _, l := range links {
+				link := l.(map[string]any)
+				f.nextID++
+				f.links[tag] = append(f.links[tag], &releaseLink{ID: f.nextID, Name: link["name"].(string), URL: link["url"].(string)})
+			}
+		}
+		f.Reply(w, http.StatusCreated, f.release(tag))
+
+	case match(releasePattern):
+		tag := m[1]
+		if _, ok := f.releases[tag]; !ok {
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not Found"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			f.Reply(w, http.StatusOK, f.release(tag))
+		case http.MethodPut:
+			var body map[string]any
+			if !f.Decode(w, r, &body) {
+				return
+			}
+			for k, v := range body {
+				f.releases[tag][k] = v
+			}
+			f.Reply(w, http.StatusOK, f.release(tag))
+		case http.MethodDelete:
+			resp := f.release(tag)
+			delete(f.releases, tag)
+			f.Reply(w, http.StatusOK, resp)
+		}
+
+	case match(linksPattern):
+		tag := m[1]
+		switch r.Method {
+		case http.MethodPost:
+			var body map[string]any
+			if !f.Decode(w, r, &body) {
+				return
+			}
+			for _, link := range f.links[tag] {
+				if link.Name == body["name"] {
+					f.Reply(w, http.StatusBadRequest, map[string]any{"message": map[string]any{"name": []string{"has already been taken"}}})
+					return
+				}
+			}
+			f.nextID++
+			link := &releaseLink{ID: f.nextID, Name: body["name"].(string), URL: body["url"].(string)}
+			f.links[tag] = append(f.links[tag], link)
+			f.Reply(w, http.StatusCreated, link)
+		case http.MethodDelete:
+			id, _ := strconv.ParseInt(m[2], 10, 64)
+			for i, link := range f.links[tag] {
+				if link.ID == id {
+					f.links[tag] = append(f.links[tag][:i], f.links[tag][i+1:]...)
+					f.Reply(w, http.StatusOK, link)
+					return
+				}
+			}
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not found"})
+		}
+
+	case match(genericPattern):
+		version, name := m[1], m[2]
+		switch r.Method {
+		case http.MethodPut:
+			content, err := io.ReadAll(r.Body)
+			if !f.Check(w, err) {
+				return
+			}
+			assert.Equal(f.T, r.ContentLength, int64(len(content)))
+			f.nextID++
+			sum := sha256.Sum256(content)
+			f.files = append(f.files, &fakePackageFile{
+				packageFile: packageFile{ID: f.nextID, FileName: name, Size: int64(len(content)), FileSHA256: hex.EncodeToString(sum[:])},
+				version:     version,
+				content:     content,
+				contentType: r.Header.Get("Content-Type"),
+			})
+			f.Reply(w, http.StatusCreated, map[string]any{"message": "201 Created"})
+		case http.MethodGet:
+			for i := len(f.files) - 1; i >= 0; i-- {
+				if file := f.files[i]; file.version == version && file.FileName == name {
+					_, _ = w.Write(file.content)
+					return
+				}
+			}
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Package Not Found"})
+		}
+
+	case r.Method == http.MethodGet && path == "/api/v4/projects/group%2Fsub%2Fapp/packages":
+		assert.Equal(f.T, "generic", r.URL.Query().Get("package_type"))
+		var packages []*packageInfo
+		for _, version := range f.versions() {
+			packages = append(packages, &packageInfo{ID: f.packageID(version), Name: "app", Version: version})
+		}
+		f.Reply(w, http.StatusOK, packages)
+
+	case match(packageFilePattern):
+		version := f.packageVersion(m[1])
+		switch r.Method {
+		case http.MethodGet:
+			var list []packageFile
+			for _, file := range f.files {
+				if file.version == version {
+					list = append(list, file.packageFile)
+				}
+			}
+			f.Reply(w, http.StatusOK, list)
+		case http.MethodDelete:
+			id, _ := strconv.ParseInt(m[2], 10, 64)
+			for i, file := range f.files {
+				if file.ID == id {
+					f.files = append(f.files[:i], f.files[i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not found"})
+		}
+
+	case r.Method == http.MethodDelete && match(packagePattern):
+		version := f.packageVersion(m[1])
+		var files []*fakePackageFile
+		for _, file := range f.files {
+			if file.version != version {
+				files = append(files, file)
+			}
+		}
+		f.files = files
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && path == "/api/v4/projects/group%2Ftap":
+		f.Reply(w, http.StatusOK, map[string]any{"default_branch": "main"})
+
+	case match(repoFilePattern):
+		assert.Equal(f.T, "Formula%2Fapp.rb", m[1])
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(f.T, "main", r.URL.Query().Get("ref"))
+			if _, ok := f.repo["Formula/app.rb"]; !ok {
+				f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 File Not Found"})
+				return
+			}
+			f.Reply(w, http.StatusOK, map[string]any{"file_path": "Formula/app.rb"})
+		case http.MethodPost, http.MethodPut:
+			var body map[string]string
+			if !f.Decode(w, r, &body) {
+				return
+			}
+			assert.Equal(f.T, "main", body["branch"])
+			_, exists := f.repo["Formula/app.rb"]
+			if exists != (r.Method == http.MethodPut) {
+				f.Reply(w, http.StatusBadRequest, map[string]any{"message": "A file with this name already exists"})
+				return
+			}
+			f.repo["Formula/app.rb"] = body["content"]
+			f.commits = append(f.commits, body["commit_message"])
+			f.Reply(w, http.StatusOK, map[string]any{"file_path": "Formula/app.rb", "branch": "main"})
+		}
+
+	default:
+		f.Unexpected(w, r)
+	}
+}
+
+// release renders a release with its links
+func (f *fakeGitLab) release(tag string) map[string]any {
+	r := f.releases[tag]
+	upcoming := false
+	if releasedAt, ok := r["released_at"].(string); ok {
+		date, err := time.Parse(time.RFC3339, releasedAt)
+		assert.NoError(f.T, err)
+		upcoming = date.After(time.Now())
+	}
+	links := f.links[tag]
+	if links == nil {
+		links = []*releaseLink{}
+	}
+	return map[string]any{
+		"tag_name":         tag,
+		"name":             r["name"],
+		"description":      r["description"],
+		"upcoming_release": upcoming,
+		"assets":           map[string]any{"links": links},
+	}
+}
+
+func (f *fakeGitLab) versions() []string {
+	var versions []string
+	seen := map[string]bool{}
+	for _, file := range f.files {
+		if !seen[file.version] {
+			seen[file.version] = true
+			versions = append(versions, file.version)
+		}
+	}
+	return versions
+}
+
+func (f *fakeGitLab) packageID(version string) int64 {
+	for i, v := range f.versions() {
+		if v == version {
+			return int64(1000 + i)
+		}
+	}
+	return 0
+}
+
+func (f *fakeGitLab) packageVersion(id string) string {
+	for _, v := range f.versions() {
+		if strconv.FormatInt(f.packageID(v), 10) == id {
+			return v
+		}
+	}
+	return ""
+}
+
+func Test_Release(t *testing.T) {
+	f, c := newFakeGitLab(t)
+	dir := t.TempDir()
+	assets := map[string][]byte{
+		"app_linux_amd64.tar.gz": bytes.Repeat([]byte("gorocket"), 4096),
+		"checksums.txt":          []byte("abc  app_linux_amd64.tar.gz\n"),
+	}
+	for name, content := range assets {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0644))
+	}
+	upload := func(release *backend.Release, name string) {
+		var written int64
+		require.NoError(t, c.UploadAsset(context.Background(), backend.UploadAssetParams{
+			Owner:    "group/sub",
+			Repo:     "app",
+			Release:  release,
+			Name:     name,
+			Path:     filepath.Join(dir, name),
+			Progress: func(w, total int64) { written = w },
+		}))
+		assert.Equal(t, int64(len(assets[name])), written)
+	}
+
+	// Missing releases are nil
+	release, err := c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0"})
+	require.NoError(t, err)
+	assert.Nil(t, release)
+
+	// Drafts are pending until they are published
+	release, err = c.CreateRelease(backend.CreateReleaseParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0", Name: "v1.0.0", Body: "notes", Draft: true})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{Tag: "v1.0.0", Name: "v1.0.0", Body: "notes", Draft: true, Pending: true}, release)
+	assert.Empty(t, f.releases)
+
+	// Assets of pending releases are only uploaded to the generic package
+	upload(release, "app_linux_amd64.tar.gz")
+	upload(release, "checksums.txt")
+	assert.Equal(t, "application/gzip", f.files[0].contentType)
+	assert.Empty(t, f.links)
+
+	list, err := c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "group/sub", Repo: "app", Release: release})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	for _, asset := range list {
+		sum := sha256.Sum256(assets[asset.Name])
+		assert.Equal(t, int64(len(assets[asset.Name])), asset.Size)
+		assert.Equal(t, "uploaded", asset.State)
+		assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), asset.Digest)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DownloadAsset(backend.DownloadAssetParams{Owner: "group/sub", Repo: "app", Release: release, Asset: list[0]}, &buf))
+	assert.Equal(t, assets[list[0].Name], buf.Bytes())
+
+	// Publishing creates the release with links to the package files
+	release, err = c.PublishRelease(backend.PublishReleaseParams{Owner: "group/sub", Repo: "app", Release: release})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{Tag: "v1.0.0", Name: "v1.0.0", Body: "notes"}, release)
+	require.Len(t, f.links["v1.0.0"], 2)
+	assert.Contains(t, f.links["v1.0.0"][0].URL, "/api/v4/projects/group%2Fsub%2Fapp/packages/generic/app/v1.0.0/app_linux_amd64.tar.gz")
+
+	// Replacing an asset removes the link and the package file
+	list, err = c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "group/sub", Repo: "app", Release: release})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.NoError(t, c.DeleteReleaseAsset(backend.DeleteReleaseAssetParams{Owner: "group/sub", Repo: "app", Release: release, Asset: list[1]}))
+	assert.Len(t, f.links["v1.0.0"], 1)
+	assert.Len(t, f.files, 1)
+
+	// Assets of existing releases are linked as they are uploaded
+	upload(release, "checksums.txt")
+	list, err = c.ListReleaseAssets(backend.ListReleaseAssetsParams{Owner: "group/sub", Repo: "app", Release: release})
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	release, err = c.UpdateRelease(backend.UpdateReleaseParams{Owner: "group/sub", Repo: "app", Release: release, Body: "new notes"})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{Tag: "v1.0.0", Name: "v1.0.0", Body: "new notes"}, release)
+
+	// Deleting the release removes its package too
+	require.NoError(t, c.DeleteRelease(backend.DeleteReleaseParams{Owner: "group/sub", Repo: "app", Release: release}))
+	assert.Empty(t, f.releases)
+	assert.Empty(t, f.files)
+}
+
+func Test_PendingRelease(t *testing.T) {
+	f, c := newFakeGitLab(t)
+	path := filepath.Join(t.TempDir(), "checksums.txt")
+	require.NoError(t, os.WriteFile(path, []byte("abc  app_linux_amd64.tar.gz\n"), 0644))
+
+	release, err := c.CreateRelease(backend.CreateReleaseParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0", Name: "v1.0.0", Draft: true})
+	require.NoError(t, err)
+	require.NoError(t, c.UploadAsset(context.Background(), backend.UploadAssetParams{Owner: "group/sub", Repo: "app", Release: release, Name: "checksums.txt", Path: path}))
+
+	// Deleting a pending release removes the uploaded package files
+	require.NoError(t, c.DeleteRelease(backend.DeleteReleaseParams{Owner: "group/sub", Repo: "app", Release: release}))
+	assert.Empty(t, f.files)
+
+	// Pending releases kept as drafts are created as upcoming releases
+	require.NoError(t, c.UploadAsset(context.Background(), backend.UploadAssetParams{Owner: "group/sub", Repo: "app", Release: release, Name: "checksums.txt", Path: path}))
+	release, err = c.PublishRelease(backend.PublishReleaseParams{Owner: "group/sub", Repo: "app", Release: release, Draft: true})
+	require.NoError(t, err)
+	assert.Equal(t, &backend.Release{Tag: "v1.0.0", Name: "v1.0.0", Draft: true}, release)
+	assert.Len(t, f.links["v1.0.0"], 1)
+
+	// Publishing them releases them now
+	release, err = c.PublishRelease(backend.PublishReleaseParams{Owner: "group/sub", Repo: "app", Release: release})
+	require.NoError(t, err)
+	assert.False(t, release.Draft)
+}
+
+func Test_UpdateFile(t *testing.T) {
+	f, c := newFakeGitLab(t)
+
+	for _, content := range []string{"class App < Formula; end", "class App < Formula; version '2'; end"} {
+		require.NoError(t, c.UpdateFile(backend.UpdateFileParams{
+			Owner:         "group",
+			Repo:          "tap",
+			Path:          "Formula/app.rb",
+			Content:       content,
+			CommitMessage: fmt.Sprintf("Update app (%d)", len(f.commits)+1),
+		}))
+		assert.Equal(t, content, f.repo["Formula/app.rb"])
+	}
+	assert.Equal(t, []string{"Update app (1)", "Update app (2)"}, f.commits)
+}
+
+func Test_Error(t *testing.T) {
+	f, c := newFakeGitLab(t)
+
+	_, err := c.CreateRelease(backend.CreateReleaseParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0"})
+	require.NoError(t, err)
+	_, err = c.CreateRelease(backend.CreateReleaseParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0"})
+	assert.EqualError(t, err, "failed to create release: GitLab API returned 409: Release already exists")
+
+	c, err = New("invalid", Options{APIURL: f.URL + "/api/v4"})
+	require.NoError(t, err)
+	_, err = c.GetReleaseByTag(backend.GetReleaseByTagParams{Owner: "group/sub", Repo: "app", Tag: "v1.0.0"})
+	assert.EqualError(t, err, "failed to get release: GitLab API returned 401: 401 Unauthorized")
+}
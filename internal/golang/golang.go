@@ -0,0 +1,39 @@
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// Platform represents an OS/arch pair supported by the Go toolchain
+type Platform struct {
+	OS           string `json:"GOOS"`
+	Arch         string `json:"GOARCH"`
+	CgoSupported bool   `json:"CgoSupported"`
+	FirstClass   bool   `json:"FirstClass"`
+}
+
+// Client provides Go toolchain operations
+type Client struct{}
+
+// New creates a new Go toolchain client
+func New() *Client {
+	return &Client{}
+}
+
+// ListPlatforms retrieves the platforms supported by the Go toolchain
+func (c *Client) ListPlatforms() ([]Platform, error) {
+	cmd := exec.Command("go", "tool", "dist", "list", "-json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list platforms: %w", err)
+	}
+
+	var platforms []Platform
+	if err := json.Unmarshal(output, &platforms); err != nil {
+		return nil, fmt.Errorf("failed to parse platforms: %w", err)
+	}
+
+	return platforms, nil
+}
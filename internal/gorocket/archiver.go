@@ -0,0 +1,496 @@
+package gorocket
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveEntry represents a file written to an archive
+type archiveEntry struct {
+	Src  string
+	Name string
+	Mode os.FileMode
+}
+
+// resolveArchiveFiles expands the extra files included in every archive.
+// Entry names are relative to the top-level directory of the archive.
+func resolveArchiveFiles(archives config.Archives) ([]archiveEntry, error) {
+	switch archives.MissingFiles {
+	case "", "error", "warn":
+	default:
+		return nil, fmt.Errorf("invalid archives.missing_files: %s (expected error or warn)", archives.MissingFiles)
+	}
+
+	var entries []archiveEntry
+	seen := map[string]string{}
+	for _, file := range archives.Files {
+		matches, err := filepath.Glob(file.Src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive file pattern %q: %w", file.Src, err)
+		}
+
+		// Handle globs that match nothing
+		if len(matches) == 0 {
+			if archives.MissingFiles == "warn" {
+				fmt.Printf("Warning: no files match %s\n", file.Src)
+				continue
+			}
+			return nil, fmt.Errorf("no files match %s (set archives.missing_files to warn to ignore)", file.Src)
+		}
+
+		for _, match := range matches {
+			// Include directories recursively
+			if err := filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return nil
+				}
+
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+
+				// Determine path in archive
+				name := filepath.ToSlash(p)
+				if file.StripParent {
+					name = path.Base(name)
+				}
+				name = path.Join(file.Dst, name)
+				if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+					return fmt.Errorf("%s would be placed outside of the archive directory", p)
+				}
+				if other, ok := seen[name]; ok {
+					return fmt.Errorf("%s and %s would both be placed at %s", other, p, name)
+				}
+				seen[name] = p
+
+				// Determine file mode
+				mode := info.Mode().Perm()
+				if file.Mode != 0 {
+					mode = os.FileMode(file.Mode).Perm()
+				}
+
+				entries = append(entries, archiveEntry{Src: p, Name: name, Mode: mode})
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("failed to add archive files: %w", err)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// archiveFormats lists the supported archive formats
+var archiveFormats = []string{"tar.gz", "tar.xz", "tar.zst", "tar", "zip", "gz", "binary"}
+
+// validateArchiveFormats checks the configured archive formats
+func validateArchiveFormats(archives config.Archives) error {
+	formats := []string{archives.Format}
+	for _, override := range archives.FormatOverrides {
+		formats = append(formats, override.Format)
+	}
+
+	for _, format := range formats {
+		if format != "" && !slices.Contains(archiveFormats, format) {
+			return fmt.Errorf("unsupported archive format: %s (expected one of %s)", format, strings.Join(archiveFormats, ", "))
+		}
+	}
+	return nil
+}
+
+// defaultArchiveNameTemplate is the archive name used unless archives.name_template is set
+const defaultArchiveNameTemplate = "{{ .Binary }}_{{ .Version }}_{{ .Os }}_{{ .ArchName }}"
+
+// createArchive creates an archive from build output and extra files
+func (b *Builder) createArchive(output *BuildOutput, name string, buildInfo *BuildInfo, archives config.Archives, files []archiveEntry) (string, error) {
+	data := targetTemplateData(templateData(buildInfo), output.OS, output.Arch, output.Variant)
+	data["Binary"] = name
+
+	// Determine archive name
+	baseName, err := renderArchiveName(archives, data)
+	if err != nil {
+		return "", err
+	}
+
+	// Determine top-level directory
+	dirName := ""
+	if !archives.WrapInDirectory.Disabled {
+		dirName = baseName
+		if archives.WrapInDirectory.Name != "" {
+			if dirName, err = archives.WrapInDirectory.Name.Execute(data); err != nil {
+				return "", fmt.Errorf("failed to render archive directory: %w", err)
+			}
+			dirName = path.Clean(dirName)
+			if dirName == ".." || strings.HasPrefix(dirName, "../") || path.IsAbs(dirName) {
+				return "", fmt.Errorf("invalid archive directory: %s", dirName)
+			}
+		}
+	}
+
+	modTime := archiveModTime(buildInfo)
+
+	// Single file formats contain just the binary
+	if output.Format == "gz" || output.Format == "binary" {
+		if len(output.Binaries) != 1 || len(files) > 0 {
+			return "", fmt.Errorf("%s format can only contain a single binary (disable archives.bundle and archives.files)", output.Format)
+		}
+
+		fileName := archiveFileName(baseName, output.OS, "binary")
+		if output.Format == "gz" {
+			return b.createGz(output.Binaries[0].Path, fileName, modTime)
+		}
+		return b.copyBinary(output.Binaries[0].Path, fileName)
+	}
+
+	// Sort entries so that archives are reproducible regardless of build order
+	var entries []archiveEntry
+	for _, binary := range output.Binaries {
+		entries = append(entries, archiveEntry{
+			Src:  binary.Path,
+			Name: path.Join(dirName, binary.Name),
+			Mode: 0755,
+		})
+	}
+	for _, file := range files {
+		entries = append(entries, archiveEntry{
+			Src:  file.Src,
+			Name: path.Join(dirName, file.Name),
+			Mode: file.Mode,
+		})
+	}
+	slices.SortFunc(entries, func(a, b archiveEntry) int { return strings.Compare(a.Name, b.Name) })
+
+	if output.Format == "zip" {
+		return b.createZip(entries, archiveFileName(baseName, output.OS, output.Format), modTime)
+	}
+	return b.createTar(entries, archiveFileName(baseName, output.OS, output.Format), output.Format, modTime)
+}
+
+// renderArchiveName renders the archive name without extension
+func renderArchiveName(archives config.Archives, data map[string]any) (string, error) {
+	nameTemplate := archives.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultArchiveNameTemplate
+	}
+	baseName, err := nameTemplate.Execute(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render archive name: %w", err)
+	}
+	if err := validateArchiveName(baseName); err != nil {
+		return "", err
+	}
+	return baseName, nil
+}
+
+// archiveFileName returns the file name of an archive in the dist directory
+func archiveFileName(baseName, goos, format string) string {
+	switch format {
+	case "binary":
+		if goos == "windows" {
+			return baseName + ".exe"
+		}
+		return baseName
+	case "gz":
+		return archiveFileName(baseName, goos, "binary") + ".gz"
+	default:
+		return baseName + "." + format
+	}
+}
+
+// checkArchiveNames renders the archive names of every job and reports archives that would overwrite each other
+func checkArchiveNames(buildInfo *BuildInfo, jobs []*buildJob, archives config.Archives) error {
+	var names []string
+	targets := map[string][]string{}
+	add := func(data map[string]any, binary, target string, job *buildJob) error {
+		data = maps.Clone(data)
+		data["Binary"] = binary
+		baseName, err := renderArchiveName(archives, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+		name := archiveFileName(baseName, job.OS, archives.FormatFor(job.OS))
+		if _, ok := targets[name]; !ok {
+			names = append(names, name)
+		}
+		targets[name] = append(targets[name], target)
+		return nil
+	}
+
+	for _, job := range jobs {
+		platform := platformName(job.OS, job.Arch, job.Variant)
+		data := targetTemplateData(templateData(buildInfo), job.OS, job.Arch, job.Variant)
+
+		if archives.Bundle {
+			if err := add(data, filepath.Base(buildInfo.Module), platform, job); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, build := range job.Builds {
+			binaryName, err := renderBinaryName(build, data, job.OS)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", platform, build.ID, err)
+			}
+			if err := add(data, strings.TrimSuffix(binaryName, ".exe"), platform+" "+build.ID, job); err != nil {
+				return err
+			}
+		}
+	}
+
+	var collisions []string
+	for _, name := range names {
+		if len(targets[name]) > 1 {
+			collisions = append(collisions, fmt.Sprintf("  - %s: %s", name, strings.Join(targets[name], ", ")))
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("archive names collide (use .Binary, .Os, .ArchName in archives.name_template):\n%s", strings.Join(collisions, "\n"))
+	}
+	return nil
+}
+
+// validateArchiveName checks that an archive name is a plain file name
+func validateArchiveName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid archive name: %q", name)
+	}
+	return nil
+}
+
+// archiveModTime returns the modification time of archive entries.
+// SOURCE_DATE_EPOCH takes precedence over the commit date.
+func archiveModTime(buildInfo *BuildInfo) time.Time {
+	if os.Getenv("SOURCE_DATE_EPOCH") != "" || buildInfo.CommitDate.IsZero() {
+		return buildInfo.Date.UTC().Truncate(time.Second)
+	}
+	return buildInfo.CommitDate.UTC().Truncate(time.Second)
+}
+
+// createTar creates a tar archive, compressed according to the format
+func (b *Builder) createTar(entries []archiveEntry, archiveName, format string, modTime time.Time) (string, error) {
+	archivePath := filepath.Join(b.distDir, archiveName)
+
+	// Create archive file
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// Compression writer with fixed headers and compression levels
+	var w io.Writer = file
+	switch format {
+	case "tar.gz":
+		gzipWriter, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+		if err != nil {
+			return "", fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		gzipWriter.ModTime = modTime
+		defer func() { _ = gzipWriter.Close() }()
+		w = gzipWriter
+	case "tar.xz":
+		xzWriter, err := xz.NewWriter(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to create xz writer: %w", err)
+		}
+		defer func() { _ = xzWriter.Close() }()
+		w = xzWriter
+	case "tar.zst":
+		zstdWriter, err := zstd.NewWriter(file, zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return "", fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		defer func() { _ = zstdWriter.Close() }()
+		w = zstdWriter
+	}
+
+	// tar writer
+	tarWriter := tar.NewWriter(w)
+	defer func() { _ = tarWriter.Close() }()
+
+	for _, entry := range entries {
+		if err := addTarFile(tarWriter, entry, modTime); err != nil {
+			return "", err
+		}
+	}
+
+	return archivePath, nil
+}
+
+// createGz compresses a single file with gzip
+func (b *Builder) createGz(src, fileName string, modTime time.Time) (string, error) {
+	archivePath := filepath.Join(b.distDir, fileName+".gz")
+
+	// Open source file
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	// Create archive file
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// gzip writer with a fixed header and compression level
+	gzipWriter, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	gzipWriter.Name = fileName
+	gzipWriter.ModTime = modTime
+	defer func() { _ = gzipWriter.Close() }()
+
+	if _, err := io.Copy(gzipWriter, srcFile); err != nil {
+		return "", fmt.Errorf("failed to write file to gzip: %w", err)
+	}
+
+	return archivePath, nil
+}
+
+// copyBinary copies a binary to the dist directory as is
+func (b *Builder) copyBinary(src, fileName string) (string, error) {
+	dstPath := filepath.Join(b.distDir, fileName)
+
+	// Open source file
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	// Create destination file
+	dstFile, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create binary file: %w", err)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return "", fmt.Errorf("failed to copy binary: %w", err)
+	}
+
+	return dstPath, nil
+}
+
+// addTarFile writes a single file to a tar archive
+func addTarFile(tarWriter *tar.Writer, entry archiveEntry, modTime time.Time) error {
+	// Open source file
+	srcFile, err := os.Open(entry.Src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	// Get file info
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// Create tar header with fixed ownership and timestamps
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entry.Name,
+		Mode:     int64(entry.Mode),
+		Size:     srcInfo.Size(),
+		ModTime:  modTime,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "root",
+		Gname:    "root",
+		Format:   tar.FormatUSTAR,
+	}
+
+	// Write header
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	// Copy file content
+	if _, err := io.Copy(tarWriter, srcFile); err != nil {
+		return fmt.Errorf("failed to write file to tar: %w", err)
+	}
+
+	return nil
+}
+
+// createZip creates a zip archive
+func (b *Builder) createZip(entries []archiveEntry, archiveName string, modTime time.Time) (string, error) {
+	archivePath := filepath.Join(b.distDir, archiveName)
+
+	// Create archive file
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// zip writer with a fixed compression level
+	zipWriter := zip.NewWriter(file)
+	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	})
+	defer func() { _ = zipWriter.Close() }()
+
+	for _, entry := range entries {
+		if err := addZipFile(zipWriter, entry, modTime); err != nil {
+			return "", err
+		}
+	}
+
+	return archivePath, nil
+}
+
+// addZipFile writes a single file to a zip archive
+func addZipFile(zipWriter *zip.Writer, entry archiveEntry, modTime time.Time) error {
+	// Open source file
+	srcFile, err := os.Open(entry.Src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	// Create zip entry with a fixed timestamp
+	header := &zip.FileHeader{
+		Name:     entry.Name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	header.SetMode(entry.Mode)
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+
+	// Copy file content
+	if _, err := io.Copy(writer, srcFile); err != nil {
+		return fmt.Errorf("failed to write file to zip: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,615 @@
+package gorocket
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func Test_renderArchiveName(t *testing.T) {
+	data := targetTemplateData(templateData(&BuildInfo{Module: "github.com/owner/app", Version: "v1.2.3"}), "linux", "arm", "7")
+	data["Binary"] = "app"
+
+	tests := []struct {
+		name     string
+		archives config.Archives
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default template",
+			expected: "app_v1.2.3_linux_armv7",
+		},
+		{
+			name:     "custom template",
+			archives: config.Archives{NameTemplate: "{{ .ProjectName }}-{{ .Os }}-{{ .Arch }}{{ .Variant }}"},
+			expected: "app-linux-arm7",
+		},
+		{
+			name:     "path separator",
+			archives: config.Archives{NameTemplate: "{{ .Os }}/{{ .Arch }}"},
+			wantErr:  true,
+		},
+		{
+			name:     "empty name",
+			archives: config.Archives{NameTemplate: "{{ .Prerelease }}"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid template",
+			archives: config.Archives{NameTemplate: "{{ .Os"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := renderArchiveName(tt.archives, data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func Test_archiveFileName(t *testing.T) {
+	tests := []struct {
+		goos     string
+		format   string
+		expected string
+	}{
+		{goos: "linux", format: "tar.gz", expected: "app.tar.gz"},
+		{goos: "linux", format: "tar.zst", expected: "app.tar.zst"},
+		{goos: "windows", format: "zip", expected: "app.zip"},
+		{goos: "linux", format: "binary", expected: "app"},
+		{goos: "windows", format: "binary", expected: "app.exe"},
+		{goos: "linux", format: "gz", expected: "app.gz"},
+		{goos: "windows", format: "gz", expected: "app.exe.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos+" "+tt.format, func(t *testing.T) {
+			assert.Equal(t, tt.expected, archiveFileName("app", tt.goos, tt.format))
+		})
+	}
+}
+
+func Test_checkArchiveNames(t *testing.T) {
+	buildInfo := &BuildInfo{Module: "github.com/owner/app", Version: "v1.2.3"}
+	jobs, err := expandBuildJobs([]config.Build{
+		{ID: "server", Binary: "server", Targets: []config.Target{{OS: "linux", Arch: []string{"amd64", "arm"}, Goarm: []string{"6", "7"}}}},
+		{ID: "cli", Binary: "cli", Targets: []config.Target{{OS: "linux", Arch: []string{"amd64"}}}},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		archives config.Archives
+		expected string
+	}{
+		{
+			name: "default template",
+		},
+		{
+			name:     "bundled archives",
+			archives: config.Archives{Bundle: true},
+		},
+		{
+			name:     "template without variant",
+			archives: config.Archives{NameTemplate: "{{ .Binary }}_{{ .Os }}_{{ .Arch }}"},
+			expected: "archive names collide (use .Binary, .Os, .ArchName in archives.name_template):\n" +
+				"  - server_linux_arm.tar.gz: linux/armv6 server, linux/armv7 server",
+		},
+		{
+			name:     "template without binary",
+			archives: config.Archives{NameTemplate: "{{ .ProjectName }}_{{ .Os }}_{{ .ArchName }}"},
+			expected: "archive names collide (use .Binary, .Os, .ArchName in archives.name_template):\n" +
+				"  - app_linux_amd64.tar.gz: linux/amd64 server, linux/amd64 cli",
+		},
+		{
+			name:     "format overrides",
+			archives: config.Archives{NameTemplate: "{{ .Binary }}_{{ .Os }}", FormatOverrides: []config.FormatOverride{{OS: "linux", Format: "zip"}}},
+			expected: "archive names collide (use .Binary, .Os, .ArchName in archives.name_template):\n" +
+				"  - server_linux.zip: linux/amd64 server, linux/armv6 server, linux/armv7 server",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkArchiveNames(buildInfo, jobs, tt.archives)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+// testBinaries writes binaries with the given names to a temporary directory, modified at modTime
+func testBinaries(t *testing.T, modTime time.Time, names ...string) []*Binary {
+	dir := t.TempDir()
+	var binaries []*Binary
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte("binary "+name), 0700))
+		require.NoError(t, os.Chtimes(p, modTime, modTime))
+		binaries = append(binaries, &Binary{BuildID: name, Name: name, Path: p})
+	}
+	return binaries
+}
+
+func Test_createArchive_reproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	commitDate := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		format   string
+		binaries []string
+	}{
+		{format: "tar.gz", binaries: []string{"app", "cli"}},
+		{format: "tar.xz", binaries: []string{"app", "cli"}},
+		{format: "tar.zst", binaries: []string{"app", "cli"}},
+		{format: "tar", binaries: []string{"app", "cli"}},
+		{format: "zip", binaries: []string{"app", "cli"}},
+		{format: "gz", binaries: []string{"app"}},
+		{format: "binary", binaries: []string{"app"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Build twice at different times, with different file times and binary order
+			var contents [][]byte
+			for i := range 2 {
+				binaries := testBinaries(t, time.Now().Add(time.Duration(i)*time.Hour), tt.binaries...)
+				if i > 0 {
+					slices.Reverse(binaries)
+				}
+				b := &Builder{distDir: t.TempDir()}
+				buildInfo := &BuildInfo{Module: "github.com/owner/app", Version: "v1.2.3", CommitDate: commitDate, Date: time.Now().Add(time.Duration(i) * time.Hour)}
+				output := &BuildOutput{OS: "linux", Arch: "amd64", Format: tt.format, Binaries: binaries}
+
+				archivePath, err := b.createArchive(output, "app", buildInfo, config.Archives{}, nil)
+				require.NoError(t, err)
+				content, err := os.ReadFile(archivePath)
+				require.NoError(t, err)
+				contents = append(contents, content)
+			}
+			assert.Equal(t, contents[0], contents[1])
+		})
+	}
+}
+
+func Test_createArchive_headers(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	commitDate := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	buildInfo := &BuildInfo{Module: "github.com/owner/app", Version: "v1.2.3", CommitDate: commitDate, Date: time.Now()}
+	binaries := testBinaries(t, time.Now(), "cli", "app")
+	expectedNames := []string{"app_v1.2.3_linux_amd64/app", "app_v1.2.3_linux_amd64/cli"}
+
+	t.Run("tar", func(t *testing.T) {
+		b := &Builder{distDir: t.TempDir()}
+		archivePath, err := b.createArchive(&BuildOutput{OS: "linux", Arch: "amd64", Format: "tar", Binaries: binaries}, "app", buildInfo, config.Archives{}, nil)
+		require.NoError(t, err)
+
+		file, err := os.Open(archivePath)
+		require.NoError(t, err)
+		defer func() { _ = file.Close() }()
+
+		var names []string
+		tarReader := tar.NewReader(file)
+		for {
+			header, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, header.Name)
+			assert.Equal(t, commitDate, header.ModTime.UTC())
+			assert.Equal(t, 0, header.Uid)
+			assert.Equal(t, 0, header.Gid)
+			assert.Equal(t, "root", header.Uname)
+			assert.Equal(t, "root", header.Gname)
+			assert.Equal(t, int64(0755), header.Mode)
+		}
+		assert.Equal(t, expectedNames, names)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		b := &Builder{distDir: t.TempDir()}
+		archivePath, err := b.createArchive(&BuildOutput{OS: "linux", Arch: "amd64", Format: "zip", Binaries: binaries}, "app", buildInfo, config.Archives{}, nil)
+		require.NoError(t, err)
+
+		zipReader, err := zip.OpenReader(archivePath)
+		require.NoError(t, err)
+		defer func() { _ = zipReader.Close() }()
+
+		var names []string
+		for _, file := range zipReader.File {
+			names = append(names, file.Name)
+			assert.Equal(t, commitDate, file.Modified.UTC())
+			assert.Equal(t, os.FileMode(0755), file.Mode().Perm())
+		}
+		assert.Equal(t, expectedNames, names)
+	})
+}
+
+func Test_archiveModTime(t *testing.T) {
+	commitDate := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	date := time.Date(2025, 6, 7, 8, 9, 10, 500, time.UTC)
+
+	tests := []struct {
+		name            string
+		sourceDateEpoch string
+		commitDate      time.Time
+		expected        time.Time
+	}{
+		{
+			name:       "commit date",
+			commitDate: commitDate,
+			expected:   commitDate,
+		},
+		{
+			name:            "SOURCE_DATE_EPOCH takes precedence",
+			sourceDateEpoch: "1749283750",
+			commitDate:      commitDate,
+			expected:        date.Truncate(time.Second),
+		},
+		{
+			name:     "no commit",
+			expected: date.Truncate(time.Second),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.sourceDateEpoch)
+			assert.Equal(t, tt.expected, archiveModTime(&BuildInfo{CommitDate: tt.commitDate, Date: date}))
+		})
+	}
+}
+
+// readArchive returns the contents of the files in an archive by name
+func readArchive(t *testing.T, archivePath, format string) map[string]string {
+	files := map[string]string{}
+
+	if format == "zip" {
+		zipReader, err := zip.OpenReader(archivePath)
+		require.NoError(t, err)
+		defer func() { _ = zipReader.Close() }()
+
+		for _, file := range zipReader.File {
+			r, err := file.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			_ = r.Close()
+			files[file.Name] = string(content)
+		}
+		return files
+	}
+
+	file, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	var r io.Reader = file
+	switch format {
+	case "tar.gz", "gz":
+		gzipReader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		if format == "gz" {
+			content, err := io.ReadAll(gzipReader)
+			require.NoError(t, err)
+			files[gzipReader.Name] = string(content)
+			return files
+		}
+		r = gzipReader
+	case "tar.xz":
+		r, err = xz.NewReader(file)
+		require.NoError(t, err)
+	case "tar.zst":
+		zstdReader, err := zstd.NewReader(file)
+		require.NoError(t, err)
+		defer zstdReader.Close()
+		r = zstdReader
+	case "binary":
+		content, err := io.ReadAll(file)
+		require.NoError(t, err)
+		files[filepath.Base(archivePath)] = string(content)
+		return files
+	}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+	return files
+}
+
+func Test_createArchive_formats(t *testing.T) {
+	buildInfo := &BuildInfo{Module: "github.com/owner/app", Version: "v1.2.3", Date: time.Now()}
+	binaries := testBinaries(t, time.Now(), "app")
+	windowsBinaries := testBinaries(t, time.Now(), "app.exe")
+	dir := "app_v1.2.3_linux_amd64/"
+
+	tests := []struct {
+		name     string
+		goos     string
+		format   string
+		binaries []*Binary
+		files    []archiveEntry
+		expected string
+		contents map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "tar.gz",
+			goos:     "linux",
+			format:   "tar.gz",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.tar.gz",
+			contents: map[string]string{dir + "app": "binary app"},
+		},
+		{
+			name:     "tar.xz",
+			goos:     "linux",
+			format:   "tar.xz",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.tar.xz",
+			contents: map[string]string{dir + "app": "binary app"},
+		},
+		{
+			name:     "tar.zst",
+			goos:     "linux",
+			format:   "tar.zst",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.tar.zst",
+			contents: map[string]string{dir + "app": "binary app"},
+		},
+		{
+			name:     "tar",
+			goos:     "linux",
+			format:   "tar",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.tar",
+			contents: map[string]string{dir + "app": "binary app"},
+		},
+		{
+			name:     "zip on linux",
+			goos:     "linux",
+			format:   "zip",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.zip",
+			contents: map[string]string{dir + "app": "binary app"},
+		},
+		{
+			name:     "zip on windows",
+			goos:     "windows",
+			format:   "zip",
+			binaries: windowsBinaries,
+			expected: "app_v1.2.3_windows_amd64.zip",
+			contents: map[string]string{"app_v1.2.3_windows_amd64/app.exe": "binary app.exe"},
+		},
+		{
+			name:     "gz",
+			goos:     "linux",
+			format:   "gz",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64.gz",
+			contents: map[string]string{"app_v1.2.3_linux_amd64": "binary app"},
+		},
+		{
+			name:     "gz on windows",
+			goos:     "windows",
+			format:   "gz",
+			binaries: windowsBinaries,
+			expected: "app_v1.2.3_windows_amd64.exe.gz",
+			contents: map[string]string{"app_v1.2.3_windows_amd64.exe": "binary app.exe"},
+		},
+		{
+			name:     "binary",
+			goos:     "linux",
+			format:   "binary",
+			binaries: binaries,
+			expected: "app_v1.2.3_linux_amd64",
+			contents: map[string]string{"app_v1.2.3_linux_amd64": "binary app"},
+		},
+		{
+			name:     "binary on windows",
+			goos:     "windows",
+			format:   "binary",
+			binaries: windowsBinaries,
+			expected: "app_v1.2.3_windows_amd64.exe",
+			contents: map[string]string{"app_v1.2.3_windows_amd64.exe": "binary app.exe"},
+		},
+		{
+			name:     "extra files",
+			goos:     "linux",
+			format:   "tar.gz",
+			binaries: binaries,
+			files:    []archiveEntry{{Src: binaries[0].Path, Name: "docs/app.txt", Mode: 0644}},
+			expected: "app_v1.2.3_linux_amd64.tar.gz",
+			contents: map[string]string{dir + "app": "binary app", dir + "docs/app.txt": "binary app"},
+		},
+		{
+			name:     "single file format with several binaries",
+			goos:     "linux",
+			format:   "binary",
+			binaries: testBinaries(t, time.Now(), "app", "cli"),
+			wantErr:  true,
+		},
+		{
+			name:     "single file format with extra files",
+			goos:     "linux",
+			format:   "gz",
+			binaries: binaries,
+			files:    []archiveEntry{{Src: binaries[0].Path, Name: "app.txt", Mode: 0644}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{distDir: t.TempDir()}
+			output := &BuildOutput{OS: tt.goos, Arch: "amd64", Format: tt.format, Binaries: tt.binaries}
+
+			archivePath, err := b.createArchive(output, "app", buildInfo, config.Archives{}, tt.files)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(b.distDir, tt.expected), archivePath)
+			assert.Equal(t, tt.contents, readArchive(t, archivePath, tt.format))
+		})
+	}
+}
+
+func Test_validateArchiveFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		archives config.Archives
+		wantErr  bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:     "formats and overrides",
+			archives: config.Archives{Format: "tar.zst", FormatOverrides: []config.FormatOverride{{OS: "windows", Format: "zip"}, {OS: "linux", Format: "binary"}}},
+		},
+		{
+			name:     "invalid format",
+			archives: config.Archives{Format: "rar"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid override",
+			archives: config.Archives{FormatOverrides: []config.FormatOverride{{OS: "windows", Format: "7z"}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArchiveFormats(tt.archives)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_resolveArchiveFiles(t *testing.T) {
+	// Archive file patterns are relative to the working directory
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	for _, name := range []string{"LICENSE", "README.md", "completions/app.bash", "completions/app.zsh"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(name), 0644))
+	}
+
+	tests := []struct {
+		name     string
+		archives config.Archives
+		expected []archiveEntry
+		wantErr  bool
+	}{
+		{
+			name:     "glob",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "*.md"}, {Src: "LICENSE"}}},
+			expected: []archiveEntry{
+				{Src: "README.md", Name: "README.md", Mode: 0644},
+				{Src: "LICENSE", Name: "LICENSE", Mode: 0644},
+			},
+		},
+		{
+			name:     "directory",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "completions"}}},
+			expected: []archiveEntry{
+				{Src: "completions/app.bash", Name: "completions/app.bash", Mode: 0644},
+				{Src: "completions/app.zsh", Name: "completions/app.zsh", Mode: 0644},
+			},
+		},
+		{
+			name:     "destination, strip parent and mode",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "completions/*.bash", Dst: "share", StripParent: true, Mode: 0600}}},
+			expected: []archiveEntry{
+				{Src: "completions/app.bash", Name: "share/app.bash", Mode: 0600},
+			},
+		},
+		{
+			name:     "missing files",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "CHANGELOG.md"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "missing files with warn",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "CHANGELOG.md"}, {Src: "LICENSE"}}, MissingFiles: "warn"},
+			expected: []archiveEntry{
+				{Src: "LICENSE", Name: "LICENSE", Mode: 0644},
+			},
+		},
+		{
+			name:     "invalid missing_files",
+			archives: config.Archives{MissingFiles: "ignore"},
+			wantErr:  true,
+		},
+		{
+			name:     "outside of the archive directory",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "LICENSE", Dst: ".."}}},
+			wantErr:  true,
+		},
+		{
+			name:     "same destination",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "completions/app.bash", StripParent: true}, {Src: "completions/*", StripParent: true}}},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid pattern",
+			archives: config.Archives{Files: []config.ArchiveFile{{Src: "["}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := resolveArchiveFiles(tt.archives)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, entries)
+		})
+	}
+}
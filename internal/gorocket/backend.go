@@ -0,0 +1,293 @@
+package gorocket
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/koki-develop/gorocket/internal/backend"
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/koki-develop/gorocket/internal/gitea"
+	"github.com/koki-develop/gorocket/internal/github"
+	"github.com/koki-develop/gorocket/internal/gitlab"
+)
+
+// Supported release backends
+const (
+	backendGitHub  = "github"
+	backendGitLab  = "gitlab"
+	backendGitea   = "gitea"   // Forgejo as well
+	backendForgejo = "forgejo" // Alias of gitea
+)
+
+const (
+	publicGitHubURL = "https://github.com" // Web URL of github.com
+	publicGitLabURL = "https://gitlab.com" // Web URL of gitlab.com
+)
+
+// Tokens are the API tokens of the release backends
+type Tokens struct {
+	GitHub string
+	GitLab string
+	Gitea  string
+}
+
+// hosting describes the service that hosts the repository and its releases
+type hosting struct {
+	Backend       string // github, gitlab or gitea
+	API           string // Empty for github.com
+	Upload        string // GitHub only: empty to derive it from the API URL
+	Web           string // Web URL without a trailing slash, e.g. https://github.com
+	SkipTLSVerify bool
+}
+
+// resolveHosting determines the release backend and its endpoints
+func resolveHosting(cfg *config.Config, repo *git.Repository) (hosting, error) {
+	name, err := detectBackend(cfg, repo)
+	if err != nil {
+		return hosting{}, err
+	}
+
+	switch name {
+	case backendGitLab:
+		return resolveGitLabHosting(cfg, repo)
+	case backendGitea:
+		return resolveGiteaHosting(cfg, repo)
+	default:
+		return resolveGitHubHosting(cfg)
+	}
+}
+
+// detectBackend returns release.backend, or detects the backend from the config and the remote
+func detectBackend(cfg *config.Config, repo *git.Repository) (string, error) {
+	switch cfg.Release.Backend {
+	case backendGitHub, backendGitLab, backendGitea:
+		return cfg.Release.Backend, nil
+	case backendForgejo:
+		return backendGitea, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid release.backend: %s (expected github, gitlab, gitea or forgejo)", cfg.Release.Backend)
+	}
+
+	if cfg.GitLabURLs.API != "" || cfg.GitLabURLs.Download != "" {
+		return backendGitLab, nil
+	}
+	if cfg.GiteaURLs.API != "" || cfg.GiteaURLs.Download != "" {
+		return backendGitea, nil
+	}
+	if repo != nil && isGitLabHost(repo.Host) {
+		return backendGitLab, nil
+	}
+	if repo != nil && isGiteaHost(repo.Host) {
+		return backendGitea, nil
+	}
+	return backendGitHub, nil
+}
+
+// isGitLabHost reports whether host is gitlab.com, looks like a GitLab instance or is the server of the GitLab CI job
+func isGitLabHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	return host == "gitlab.com" || strings.HasPrefix(host, "gitlab.") || host == strings.ToLower(os.Getenv("CI_SERVER_HOST"))
+}
+
+// isGiteaHost reports whether host is codeberg.org, looks like a Gitea or Forgejo instance,
+// or runs the current Gitea or Forgejo Actions job (which sets GITHUB_SERVER_URL too)
+func isGiteaHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if host == "codeberg.org" || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo.") {
+		return true
+	}
+	if isGiteaActions() {
+		server, err := url.Parse(os.Getenv("GITHUB_SERVER_URL"))
+		return err == nil && strings.ToLower(server.Host) == host
+	}
+	return false
+}
+
+// isGiteaActions reports whether gorocket runs in Gitea or Forgejo Actions
+func isGiteaActions() bool {
+	return os.Getenv("GITEA_ACTIONS") == "true" || os.Getenv("FORGEJO_ACTIONS") == "true"
+}
+
+// resolveGitHubHosting determines the GitHub endpoints (config > environment variables > github.com).
+// The remote host isn't used, since it may be an SSH host alias.
+func resolveGitHubHosting(cfg *config.Config) (hosting, error) {
+	h := hosting{
+		Backend:       backendGitHub,
+		API:           cmp.Or(cfg.GitHubURLs.API, os.Getenv("GITHUB_API_URL")),
+		Upload:        cmp.Or(cfg.GitHubURLs.Upload, os.Getenv("GITHUB_UPLOAD_URL")),
+		Web:           cmp.Or(cfg.GitHubURLs.Download, os.Getenv("GITHUB_DOWNLOAD_URL"), os.Getenv("GITHUB_SERVER_URL")),
+		SkipTLSVerify: cfg.GitHubURLs.SkipTLSVerify,
+	}
+
+	// Derive the web URL of GitHub Enterprise Server from the API URL
+	if h.Web == "" {
+		var api *url.URL
+		if h.API != "" {
+			u, err := url.Parse(h.API)
+			if err != nil || u.Host == "" {
+				return hosting{}, fmt.Errorf("invalid GitHub API URL: %s", h.API)
+			}
+			api = u
+		}
+
+		if api != nil && api.Host != "api.github.com" {
+			h.Web = api.Scheme + "://" + api.Host
+		} else {
+			h.Web = publicGitHubURL
+		}
+	}
+	h.Web = strings.TrimSuffix(h.Web, "/")
+
+	// GitHub Enterprise Server serves the API under /api/v3/
+	if h.API == "" && h.Web != publicGitHubURL {
+		h.API = h.Web + "/api/v3/"
+	}
+
+	return h, nil
+}
+
+// resolveGitLabHosting determines the GitLab endpoints (config > GitLab CI variables > git remote > gitlab.com)
+func resolveGitLabHosting(cfg *config.Config, repo *git.Repository) (hosting, error) {
+	h := hosting{
+		Backend:       backendGitLab,
+		API:           cmp.Or(cfg.GitLabURLs.API, os.Getenv("CI_API_V4_URL")),
+		Web:           cmp.Or(cfg.GitLabURLs.Download, os.Getenv("CI_SERVER_URL")),
+		SkipTLSVerify: cfg.GitLabURLs.SkipTLSVerify,
+	}
+
+	// Derive the web URL from the API URL or the remote
+	if h.Web == "" {
+		switch {
+		case h.API != "":
+			u, err := url.Parse(h.API)
+			if err != nil || u.Host == "" {
+				return hosting{}, fmt.Errorf("invalid GitLab API URL: %s", h.API)
+			}
+			h.Web = u.Scheme + "://" + u.Host
+		case repo != nil && repo.Host != "":
+			h.Web = "https://" + repo.Host
+		default:
+			h.Web = publicGitLabURL
+		}
+	}
+	h.Web = strings.TrimSuffix(h.Web, "/")
+
+	if h.API == "" {
+		h.API = h.Web + "/api/v4/"
+	}
+
+	return h, nil
+}
+
+// resolveGiteaHosting determines the Gitea endpoints (config > Actions variables > git remote).
+// Gitea is always self-hosted, so there is no default instance.
+func resolveGiteaHosting(cfg *config.Config, repo *git.Repository) (hosting, error) {
+	h := hosting{
+		Backend:       backendGitea,
+		API:           cfg.GiteaURLs.API,
+		Web:           cfg.GiteaURLs.Download,
+		SkipTLSVerify: cfg.GiteaURLs.SkipTLSVerify,
+	}
+	if isGiteaActions() {
+		h.API = cmp.Or(h.API, os.Getenv("GITHUB_API_URL"))
+		h.Web = cmp.Or(h.Web, os.Getenv("GITHUB_SERVER_URL"))
+	}
+
+	// Derive the web URL from the API URL or the remote
+	if h.Web == "" {
+		switch {
+		case h.API != "":
+			u, err := url.Parse(h.API)
+			if err != nil || u.Host == "" {
+				return hosting{}, fmt.Errorf("invalid Gitea API URL: %s", h.API)
+			}
+			h.Web = u.Scheme + "://" + u.Host
+		case repo != nil && repo.Host != "":
+			h.Web = "https://" + repo.Host
+		default:
+			return hosting{}, fmt.Errorf("gitea_urls.download is required to release to Gitea")
+		}
+	}
+	h.Web = strings.TrimSuffix(h.Web, "/")
+
+	if h.API == "" {
+		h.API = h.Web + "/api/v1/"
+	}
+
+	return h, nil
+}
+
+// newBackend creates the client of the release backend, taking tokens from the environment if not given.
+// Log messages of the client are written to log.
+func newBackend(cfg *config.Config, h hosting, tokens Tokens, verbose bool, log io.Writer) (backend.Backend, error) {
+	switch h.Backend {
+	case backendGitLab:
+		token := cmp.Or(tokens.GitLab, os.Getenv("GITLAB_TOKEN"))
+		if token == "" {
+			return nil, fmt.Errorf("GitLab token is required (use --gitlab-token or GITLAB_TOKEN env var)")
+		}
+		return gitlab.New(token, gitlab.Options{
+			APIURL:        h.API,
+			SkipTLSVerify: h.SkipTLSVerify,
+		})
+	case backendGitea:
+		token := cmp.Or(tokens.Gitea, os.Getenv("GITEA_TOKEN"))
+		if token == "" {
+			return nil, fmt.Errorf("Gitea token is required (use --gitea-token or GITEA_TOKEN env var)")
+		}
+		return gitea.New(token, gitea.Options{
+			APIURL:        h.API,
+			SkipTLSVerify: h.SkipTLSVerify,
+		})
+	default:
+		token := cmp.Or(tokens.GitHub, os.Getenv("GITHUB_TOKEN"))
+		if token == "" {
+			return nil, fmt.Errorf("GitHub token is required (use --github-token or GITHUB_TOKEN env var)")
+		}
+		return newGitHubClient(cfg, h, token, verbose, log)
+	}
+}
+
+// newGitHubClient creates a GitHub client configured by the config file
+func newGitHubClient(cfg *config.Config, h hosting, token string, verbose bool, log io.Writer) (*github.Client, error) {
+	return github.New(token, github.Options{
+		Retry: github.RetryOptions{
+			Attempts:        cfg.GitHub.Retry.Attempts,
+			InitialInterval: cfg.GitHub.Retry.InitialInterval,
+			MaxInterval:     cfg.GitHub.Retry.MaxInterval,
+			MaxWait:         cfg.GitHub.Retry.MaxWait,
+		},
+		Verbose:       verbose,
+		APIURL:        h.API,
+		UploadURL:     h.Upload,
+		SkipTLSVerify: h.SkipTLSVerify,
+		Log:           log,
+	})
+}
+
+// downloadURL returns the URL of a release asset
+func downloadURL(h hosting, repo *git.Repository, tag, name string) string {
+	if h.Backend == backendGitLab {
+		// Assets are linked with their name as the direct asset path
+		return fmt.Sprintf("%s/%s/%s/-/releases/%s/downloads/%s", h.Web, repo.Owner, repo.Name, tag, name)
+	}
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", h.Web, repo.Owner, repo.Name, tag, name)
+}
+
+// commitURL returns the URL of a commit
+func commitURL(h hosting, repo *git.Repository, hash string) string {
+	if h.Backend == backendGitLab {
+		return fmt.Sprintf("%s/%s/%s/-/commit/%s", h.Web, repo.Owner, repo.Name, hash)
+	}
+	return fmt.Sprintf("%s/%s/%s/commit/%s", h.Web, repo.Owner, repo.Name, hash)
+}
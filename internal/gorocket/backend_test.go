@@ -0,0 +1,383 @@
+package gorocket
+
+import (
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/stretchr/testify/assert"
+)
+
+// clearHostingEnv unsets the CI variables that hosting detection reads
+func clearHostingEnv(t *testing.T) {
+	for _, name := range []string{
+		"GITHUB_API_URL", "GITHUB_UPLOAD_URL", "GITHUB_DOWNLOAD_URL", "GITHUB_SERVER_URL",
+		"CI_SERVER_HOST", "CI_API_V4_URL", "CI_SERVER_URL",
+		"GITEA_ACTIONS", "FORGEJO_ACTIONS",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+// testRepository returns a repository on host, or nil if host is empty
+func testRepository(host string) *git.Repository {
+	if host == "" {
+		return nil
+	}
+	return &git.Repository{Host: host, Owner: "owner", Name: "app"}
+}
+
+func Test_detectBackend(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		env       map[string]string
+		host      string
+		expected  string
+		wantErr   string
+	}{
+		{
+			name:     "github.com",
+			host:     "github.com",
+			expected: backendGitHub,
+		},
+		{
+			name:     "unknown remote",
+			expected: backendGitHub,
+		},
+		{
+			name:     "Enterprise Server",
+			host:     "github.example.com",
+			expected: backendGitHub,
+		},
+		{
+			name:     "gitlab.com",
+			host:     "gitlab.com",
+			expected: backendGitLab,
+		},
+		{
+			name:     "self-hosted GitLab",
+			host:     "gitlab.example.com",
+			expected: backendGitLab,
+		},
+		{
+			name:     "GitLab CI server",
+			env:      map[string]string{"CI_SERVER_HOST": "git.example.com"},
+			host:     "git.example.com",
+			expected: backendGitLab,
+		},
+		{
+			name:     "GitLab CI server of another remote",
+			env:      map[string]string{"CI_SERVER_HOST": "git.example.com"},
+			host:     "github.com",
+			expected: backendGitHub,
+		},
+		{
+			name:     "codeberg.org",
+			host:     "codeberg.org",
+			expected: backendGitea,
+		},
+		{
+			name:     "self-hosted Gitea",
+			host:     "gitea.example.com",
+			expected: backendGitea,
+		},
+		{
+			name:     "self-hosted Forgejo",
+			host:     "forgejo.example.com",
+			expected: backendGitea,
+		},
+		{
+			name:     "Gitea Actions server",
+			env:      map[string]string{"GITEA_ACTIONS": "true", "GITHUB_SERVER_URL": "https://git.example.com"},
+			host:     "git.example.com",
+			expected: backendGitea,
+		},
+		{
+			name:     "Forgejo Actions server",
+			env:      map[string]string{"FORGEJO_ACTIONS": "true", "GITHUB_SERVER_URL": "https://git.example.com"},
+			host:     "git.example.com",
+			expected: backendGitea,
+		},
+		{
+			name:     "GitHub Actions server",
+			env:      map[string]string{"GITHUB_SERVER_URL": "https://git.example.com"},
+			host:     "git.example.com",
+			expected: backendGitHub,
+		},
+		{
+			name: "GitLab URLs in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GitLabURLs.API = "https://git.example.com/api/v4/"
+			},
+			host:     "git.example.com",
+			expected: backendGitLab,
+		},
+		{
+			name: "Gitea URLs in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GiteaURLs.Download = "https://git.example.com"
+			},
+			host:     "git.example.com",
+			expected: backendGitea,
+		},
+		{
+			name: "backend in the config",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Backend = "github"
+			},
+			host:     "gitlab.example.com",
+			expected: backendGitHub,
+		},
+		{
+			name: "forgejo is an alias of gitea",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Backend = "forgejo"
+			},
+			expected: backendGitea,
+		},
+		{
+			name: "invalid backend",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Backend = "bitbucket"
+			},
+			wantErr: "invalid release.backend: bitbucket (expected github, gitlab, gitea or forgejo)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearHostingEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var cfg config.Config
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			got, err := detectBackend(&cfg, testRepository(tt.host))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func Test_resolveGitHubHosting(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		env       map[string]string
+		expected  hosting
+	}{
+		{
+			name:     "github.com",
+			expected: hosting{Backend: backendGitHub, Web: "https://github.com"},
+		},
+		{
+			name:     "GitHub Actions on github.com",
+			env:      map[string]string{"GITHUB_API_URL": "https://api.github.com", "GITHUB_SERVER_URL": "https://github.com"},
+			expected: hosting{Backend: backendGitHub, API: "https://api.github.com", Web: "https://github.com"},
+		},
+		{
+			name:     "GitHub Actions on Enterprise Server",
+			env:      map[string]string{"GITHUB_API_URL": "https://github.example.com/api/v3", "GITHUB_SERVER_URL": "https://github.example.com"},
+			expected: hosting{Backend: backendGitHub, API: "https://github.example.com/api/v3", Web: "https://github.example.com"},
+		},
+		{
+			name:     "server URL",
+			env:      map[string]string{"GITHUB_SERVER_URL": "https://github.example.com/"},
+			expected: hosting{Backend: backendGitHub, API: "https://github.example.com/api/v3/", Web: "https://github.example.com"},
+		},
+		{
+			name: "API URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GitHubURLs.API = "https://github.example.com/api/v3/"
+				cfg.GitHubURLs.SkipTLSVerify = true
+			},
+			expected: hosting{Backend: backendGitHub, API: "https://github.example.com/api/v3/", Web: "https://github.example.com", SkipTLSVerify: true},
+		},
+		{
+			name: "download URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GitHubURLs.Download = "https://github.example.com"
+			},
+			env:      map[string]string{"GITHUB_SERVER_URL": "https://github.com"},
+			expected: hosting{Backend: backendGitHub, API: "https://github.example.com/api/v3/", Web: "https://github.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearHostingEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var cfg config.Config
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			h, err := resolveGitHubHosting(&cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, h)
+		})
+	}
+}
+
+func Test_resolveGitLabHosting(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		env       map[string]string
+		host      string
+		expected  hosting
+		wantErr   string
+	}{
+		{
+			name:     "gitlab.com",
+			expected: hosting{Backend: backendGitLab, API: "https://gitlab.com/api/v4/", Web: "https://gitlab.com"},
+		},
+		{
+			name:     "remote host",
+			host:     "gitlab.example.com",
+			expected: hosting{Backend: backendGitLab, API: "https://gitlab.example.com/api/v4/", Web: "https://gitlab.example.com"},
+		},
+		{
+			name:     "GitLab CI",
+			env:      map[string]string{"CI_API_V4_URL": "https://git.example.com/api/v4", "CI_SERVER_URL": "https://git.example.com"},
+			host:     "gitlab.com",
+			expected: hosting{Backend: backendGitLab, API: "https://git.example.com/api/v4", Web: "https://git.example.com"},
+		},
+		{
+			name: "API URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GitLabURLs.API = "https://git.example.com:8443/api/v4/"
+				cfg.GitLabURLs.SkipTLSVerify = true
+			},
+			env:      map[string]string{"CI_API_V4_URL": "https://gitlab.com/api/v4"},
+			expected: hosting{Backend: backendGitLab, API: "https://git.example.com:8443/api/v4/", Web: "https://git.example.com:8443", SkipTLSVerify: true},
+		},
+		{
+			name: "download URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GitLabURLs.Download = "https://git.example.com/"
+			},
+			expected: hosting{Backend: backendGitLab, API: "https://git.example.com/api/v4/", Web: "https://git.example.com"},
+		},
+		{
+			name: "invalid API URL",
+			configure: func(cfg *config.Config) {
+				cfg.GitLabURLs.API = "git.example.com"
+			},
+			wantErr: "invalid GitLab API URL: git.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearHostingEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var cfg config.Config
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			h, err := resolveGitLabHosting(&cfg, testRepository(tt.host))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, h)
+		})
+	}
+}
+
+func Test_resolveGiteaHosting(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		env       map[string]string
+		host      string
+		expected  hosting
+		wantErr   string
+	}{
+		{
+			name:     "remote host",
+			host:     "codeberg.org",
+			expected: hosting{Backend: backendGitea, API: "https://codeberg.org/api/v1/", Web: "https://codeberg.org"},
+		},
+		{
+			name:     "Gitea Actions",
+			env:      map[string]string{"GITEA_ACTIONS": "true", "GITHUB_API_URL": "https://git.example.com/api/v1", "GITHUB_SERVER_URL": "https://git.example.com"},
+			host:     "codeberg.org",
+			expected: hosting{Backend: backendGitea, API: "https://git.example.com/api/v1", Web: "https://git.example.com"},
+		},
+		{
+			name:     "GitHub Actions variables are ignored",
+			env:      map[string]string{"GITHUB_API_URL": "https://api.github.com", "GITHUB_SERVER_URL": "https://github.com"},
+			host:     "codeberg.org",
+			expected: hosting{Backend: backendGitea, API: "https://codeberg.org/api/v1/", Web: "https://codeberg.org"},
+		},
+		{
+			name: "API URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GiteaURLs.API = "https://git.example.com/api/v1/"
+				cfg.GiteaURLs.SkipTLSVerify = true
+			},
+			env:      map[string]string{"FORGEJO_ACTIONS": "true", "GITHUB_API_URL": "https://codeberg.org/api/v1"},
+			host:     "codeberg.org",
+			expected: hosting{Backend: backendGitea, API: "https://git.example.com/api/v1/", Web: "https://git.example.com", SkipTLSVerify: true},
+		},
+		{
+			name: "download URL in the config",
+			configure: func(cfg *config.Config) {
+				cfg.GiteaURLs.Download = "https://git.example.com/"
+			},
+			expected: hosting{Backend: backendGitea, API: "https://git.example.com/api/v1/", Web: "https://git.example.com"},
+		},
+		{
+			name:    "no host",
+			wantErr: "gitea_urls.download is required to release to Gitea",
+		},
+		{
+			name: "invalid API URL",
+			configure: func(cfg *config.Config) {
+				cfg.GiteaURLs.API = "git.example.com"
+			},
+			wantErr: "invalid Gitea API URL: git.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearHostingEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var cfg config.Config
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			h, err := resolveGiteaHosting(&cfg, testRepository(tt.host))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, h)
+		})
+	}
+}
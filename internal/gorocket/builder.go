@@ -1,55 +1,85 @@
 package gorocket
 
 import (
-	"archive/tar"
-	"archive/zip"
 	"bufio"
-	"compress/gzip"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/koki-develop/gorocket/internal/artifact"
 	"github.com/koki-develop/gorocket/internal/config"
 	"github.com/koki-develop/gorocket/internal/formula"
 	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/koki-develop/gorocket/internal/golang"
 	"github.com/koki-develop/gorocket/internal/util"
 )
 
 // BuildParams contains options for the build command
 type BuildParams struct {
-	Clean      bool
-	AllowDirty bool
+	Clean              bool
+	AllowDirty         bool
+	Parallelism        int
+	Targets            []string
+	VerifyReproducible bool
 }
 
 // BuildInfo holds build information
 type BuildInfo struct {
-	Module  string
-	Version string
+	Module      string
+	Version     string
+	PreviousTag string
+	Commit      string
+	ShortCommit string
+	CommitDate  time.Time
+	Date        time.Time
+	IsSnapshot  bool
 }
 
-// BuildResult represents a build result
-type BuildResult struct {
-	Version string
-	Outputs []*BuildOutput
-}
-
-// BuildOutput represents a single build output
+// BuildOutput represents a single archive and the binaries it contains
 type BuildOutput struct {
 	OS          string
 	Arch        string
-	BinaryPath  string
+	Variant     string
+	Format      string
+	Binaries    []*Binary
 	ArchivePath string
 }
 
+// Binary represents a single built binary
+type Binary struct {
+	BuildID string
+	Name    string
+	Path    string
+}
+
+// buildJob represents a single target and the builds that include it
+type buildJob struct {
+	OS      string
+	Arch    string
+	Variant string
+	Builds  []config.Build
+}
+
 // Builder provides build functionality
 type Builder struct {
 	configPath string
 	git        *git.Client
 	formula    *formula.Client
+	golang     *golang.Client
 	allowDirty bool
+	distDir    string
+	env        []string
+	artifacts  *artifact.Registry
 }
 
 // NewBuilder creates a new Builder instance
@@ -58,13 +88,21 @@ func NewBuilder(configPath string) *Builder {
 		configPath: configPath,
 		git:        git.New(),
 		formula:    formula.New(),
+		golang:     golang.New(),
+		distDir:    "dist",
+		artifacts:  artifact.New(),
 	}
 }
 
 // Build executes cross-platform builds
 func (b *Builder) Build(params BuildParams) error {
+	if params.VerifyReproducible {
+		return b.verifyReproducible(params)
+	}
+
 	// Set allowDirty flag
 	b.allowDirty = params.AllowDirty
+	b.artifacts = artifact.New()
 
 	// Get build info
 	buildInfo, err := b.getBuildInfo()
@@ -72,17 +110,27 @@ func (b *Builder) Build(params BuildParams) error {
 		return err
 	}
 
-	// Load config file
-	cfg, err := config.LoadConfig(b.configPath, map[string]any{
-		"Version": buildInfo.Version,
-		"Module":  buildInfo.Module,
-	})
+	// Load config file and expand build matrix
+	cfg, jobs, err := b.plan(buildInfo)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+
+	// Restrict the build to the requested targets
+	if len(params.Targets) > 0 {
+		jobs, err = filterJobs(jobs, params.Targets)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate every target before building anything
+	if err := b.validateJobs(jobs); err != nil {
+		return err
 	}
 
 	// Prepare dist directory
-	distDir := "dist"
+	distDir := b.distDir
 
 	// Check if dist directory exists and is not empty
 	if info, err := os.Stat(distDir); err == nil && info.IsDir() {
@@ -109,49 +157,375 @@ func (b *Builder) Build(params BuildParams) error {
 		return fmt.Errorf("failed to create dist directory: %w", err)
 	}
 
-	// Build each target
-	var outputs []*BuildOutput
-	for _, target := range cfg.Build.Targets {
-		for _, arch := range target.Arch {
-			fmt.Printf("Building %s/%s...\n", target.OS, arch)
+	// Determine parallelism (flag > config > number of CPUs)
+	parallelism := params.Parallelism
+	if parallelism <= 0 {
+		parallelism = cfg.Build.Parallelism
+	}
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
 
-			output, err := b.buildBinary(buildInfo.Module, target.OS, arch, cfg.Build.Ldflags)
-			if err != nil {
-				return fmt.Errorf("failed to build %s/%s: %w", target.OS, arch, err)
-			}
+	// Resolve archive settings once for all targets
+	if err := validateArchiveFormats(cfg.Archives); err != nil {
+		return err
+	}
+	if !cfg.Checksum.Disable {
+		if err := validateChecksumAlgorithm(cfg.Checksum.Algorithm); err != nil {
+			return err
+		}
+	}
+	archiveFiles, err := resolveArchiveFiles(cfg.Archives)
+	if err != nil {
+		return err
+	}
 
-			// Create archive
-			archivePath, err := b.createArchive(output)
-			if err != nil {
-				return fmt.Errorf("failed to create archive: %w", err)
-			}
+	// Make sure concurrent targets never write the same archive
+	if err := checkArchiveNames(buildInfo, jobs, cfg.Archives); err != nil {
+		return err
+	}
+
+	// Build each target concurrently
+	jobOutputs := make([][]*BuildOutput, len(jobs))
+	var stdoutMu sync.Mutex
+	if err := util.Parallel(context.Background(), parallelism, len(jobs), func(ctx context.Context, i int) error {
+		// Buffer per-target logs so that output of concurrent builds is not interleaved
+		var log bytes.Buffer
+		defer func() {
+			stdoutMu.Lock()
+			defer stdoutMu.Unlock()
+			_, _ = io.Copy(os.Stdout, &log)
+		}()
 
-			output.ArchivePath = archivePath
-			fmt.Printf("Created %s\n", archivePath)
+		outputs, err := b.buildTarget(ctx, &log, buildInfo, jobs[i], cfg.Archives, archiveFiles)
+		if err != nil {
+			return err
+		}
+		jobOutputs[i] = outputs
+		return nil
+	}); err != nil {
+		return err
+	}
 
-			// Remove binary file
-			if err := os.Remove(output.BinaryPath); err != nil {
-				return fmt.Errorf("failed to remove binary: %w", err)
+	// Register archives in the order of the build matrix
+	for _, outputs := range jobOutputs {
+		for _, output := range outputs {
+			if err := b.addArchive(output); err != nil {
+				return err
 			}
+		}
+	}
 
-			outputs = append(outputs, output)
+	// Generate checksums
+	if !cfg.Checksum.Disable {
+		if err := b.writeChecksums(buildInfo, cfg.Checksum.Algorithm, cfg.Checksum.Sidecar); err != nil {
+			return fmt.Errorf("failed to generate checksums: %w", err)
 		}
 	}
 
 	// Generate Homebrew Formula if configured
 	if cfg.Brew.Repository.Owner != "" && cfg.Brew.Repository.Name != "" {
-		result := &BuildResult{
-			Version: buildInfo.Version,
-			Outputs: outputs,
-		}
-		if err := b.generateFormula(buildInfo, result); err != nil {
+		if err := b.generateFormula(cfg, buildInfo); err != nil {
 			return fmt.Errorf("failed to generate formula: %w", err)
 		}
 	}
 
+	// Write artifact manifest
+	if err := b.artifacts.Write(b.distDir, buildMetadata(buildInfo)); err != nil {
+		return fmt.Errorf("failed to write artifact manifest: %w", err)
+	}
+
 	return nil
 }
 
+// addArchive registers a build output in the artifact registry
+func (b *Builder) addArchive(output *BuildOutput) error {
+	a := &artifact.Artifact{
+		Type:    artifact.TypeArchive,
+		Path:    output.ArchivePath,
+		OS:      output.OS,
+		Arch:    output.Arch,
+		Variant: output.Variant,
+		Extra:   map[string]any{"format": output.Format},
+	}
+	for _, binary := range output.Binaries {
+		a.Binaries = append(a.Binaries, artifact.Binary{BuildID: binary.BuildID, Name: binary.Name})
+	}
+	return b.artifacts.Add(a)
+}
+
+// buildMetadata returns the metadata written next to the artifacts
+func buildMetadata(buildInfo *BuildInfo) *artifact.Metadata {
+	return &artifact.Metadata{
+		ProjectName: filepath.Base(buildInfo.Module),
+		Module:      buildInfo.Module,
+		Tag:         buildInfo.Version,
+		Version:     strings.TrimPrefix(buildInfo.Version, "v"),
+		PreviousTag: buildInfo.PreviousTag,
+		Commit:      buildInfo.Commit,
+		ShortCommit: buildInfo.ShortCommit,
+		CommitDate:  buildInfo.CommitDate.UTC(),
+		Date:        buildInfo.Date.UTC().Truncate(time.Second),
+		IsSnapshot:  buildInfo.IsSnapshot,
+	}
+}
+
+// plan loads the config file and expands the build matrix
+func (b *Builder) plan(buildInfo *BuildInfo) (*config.Config, []*buildJob, error) {
+	// Load config file
+	cfg, err := config.LoadConfig(b.configPath, templateData(buildInfo))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Resolve builds
+	builds, err := resolveBuilds(cfg, filepath.Base(buildInfo.Module))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid builds configuration: %w", err)
+	}
+
+	if len(cfg.Brew.Builds) == 0 {
+		cfg.Brew.Builds = defaultBrewBuilds(cfg.Archives, builds)
+	}
+
+	jobs, err := expandBuildJobs(builds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, jobs, nil
+}
+
+// resolveBuilds returns the builds to run, filling in defaults
+func resolveBuilds(cfg *config.Config, projectName string) ([]config.Build, error) {
+	// The binary of the repository root defaults to the project name
+	rootBinary := cfg.Build.Binary
+	if rootBinary == "" {
+		rootBinary = config.Template(projectName)
+	}
+
+	// Fall back to a single build of the repository root
+	if len(cfg.Builds) == 0 {
+		return []config.Build{{
+			BuildOptions: cfg.Build.BuildOptions,
+			ID:           projectName,
+			Main:         ".",
+			Binary:       rootBinary,
+			Targets:      cfg.Build.Targets,
+			Ignore:       cfg.Build.Ignore,
+		}}, nil
+	}
+
+	builds := make([]config.Build, 0, len(cfg.Builds))
+	ids := map[string]bool{}
+	binaries := map[string]bool{}
+	for _, build := range cfg.Builds {
+		if build.Main == "" {
+			build.Main = "."
+		}
+		if build.Binary == "" {
+			if build.Main == "." {
+				build.Binary = rootBinary
+			} else {
+				build.Binary = config.Template(filepath.Base(build.Main))
+			}
+		}
+		if build.ID == "" {
+			// Templated binary names can't be used as ids
+			switch {
+			case !strings.Contains(string(build.Binary), "{{"):
+				build.ID = string(build.Binary)
+			case build.Main == ".":
+				build.ID = projectName
+			default:
+				build.ID = filepath.Base(build.Main)
+			}
+		}
+		build.BuildOptions = cfg.Build.BuildOptions.Merge(build.BuildOptions)
+		if len(build.Targets) == 0 {
+			build.Targets = cfg.Build.Targets
+		}
+		build.Ignore = append(slices.Clone(cfg.Build.Ignore), build.Ignore...)
+
+		if ids[build.ID] {
+			return nil, fmt.Errorf("duplicate build id: %s", build.ID)
+		}
+		if binaries[string(build.Binary)] {
+			return nil, fmt.Errorf("duplicate binary name: %s", build.Binary)
+		}
+		ids[build.ID] = true
+		binaries[string(build.Binary)] = true
+
+		builds = append(builds, build)
+	}
+
+	// Validate build ids referenced by brew
+	for _, id := range cfg.Brew.Builds {
+		if !ids[id] {
+			return nil, fmt.Errorf("unknown build id in brew.builds: %s", id)
+		}
+	}
+
+	return builds, nil
+}
+
+// defaultBrewBuilds returns the builds installed with Homebrew by default.
+// Bundled archives contain every build, otherwise each build has its own archive and only the first one is installed.
+func defaultBrewBuilds(archives config.Archives, builds []config.Build) []string {
+	if !archives.Bundle {
+		return []string{builds[0].ID}
+	}
+
+	ids := make([]string, len(builds))
+	for i, build := range builds {
+		ids[i] = build.ID
+	}
+	return ids
+}
+
+// expandBuildJobs groups builds by target, preserving configuration order
+func expandBuildJobs(builds []config.Build) ([]*buildJob, error) {
+	var jobs []*buildJob
+	index := map[string]*buildJob{}
+	for _, build := range builds {
+		for _, target := range build.Targets {
+			for _, arch := range target.Arch {
+				for _, variant := range target.Variants(arch) {
+					// Skip ignored targets
+					ignored, err := isIgnored(build.Ignore, target.OS, arch, variant)
+					if err != nil {
+						return nil, err
+					}
+					if ignored {
+						continue
+					}
+
+					key := platformName(target.OS, arch, variant)
+					job, ok := index[key]
+					if !ok {
+						job = &buildJob{OS: target.OS, Arch: arch, Variant: variant}
+						index[key] = job
+						jobs = append(jobs, job)
+					}
+
+					// Apply target specific build options
+					targetBuild := build
+					targetBuild.BuildOptions = build.BuildOptions.Merge(target.BuildOptions)
+					job.Builds = append(job.Builds, targetBuild)
+				}
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// isIgnored reports whether any of the ignore rules matches the given target
+func isIgnored(rules []config.Ignore, goos, goarch, variant string) (bool, error) {
+	for _, rule := range rules {
+		ok, err := rule.Match(goos, goarch, variant)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterJobs returns the jobs matching any of the given OS/arch glob patterns (e.g. linux/amd64, darwin/*).
+// Patterns are matched against both the platform name with and without the variant.
+func filterJobs(jobs []*buildJob, patterns []string) ([]*buildJob, error) {
+	var filtered []*buildJob
+	for _, job := range jobs {
+		names := []string{platformName(job.OS, job.Arch, job.Variant), job.OS + "/" + job.Arch}
+		for _, pattern := range patterns {
+			matched := false
+			for _, name := range names {
+				ok, err := path.Match(pattern, name)
+				if err != nil {
+					return nil, fmt.Errorf("invalid target pattern %q: %w", pattern, err)
+				}
+				matched = matched || ok
+			}
+			if matched {
+				filtered = append(filtered, job)
+				break
+			}
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no targets match %s", strings.Join(patterns, ", "))
+	}
+	return filtered, nil
+}
+
+// buildTarget builds and archives every binary of a single target
+func (b *Builder) buildTarget(ctx context.Context, log io.Writer, buildInfo *BuildInfo, job *buildJob, archives config.Archives, archiveFiles []archiveEntry) ([]*BuildOutput, error) {
+	platform := platformName(job.OS, job.Arch, job.Variant)
+	_, _ = fmt.Fprintf(log, "Building %s...\n", platform)
+
+	// Use a per-target output directory so that concurrent builds don't overwrite each other
+	outDir, err := os.MkdirTemp("", fmt.Sprintf("gorocket-%s_%s-", job.OS, archName(job.Arch, job.Variant)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(outDir) }()
+
+	data := templateData(buildInfo)
+
+	var outputs []*BuildOutput
+	archive := func(name string, binaries []*Binary) error {
+		output := &BuildOutput{
+			OS:       job.OS,
+			Arch:     job.Arch,
+			Variant:  job.Variant,
+			Format:   archives.FormatFor(job.OS),
+			Binaries: binaries,
+		}
+		archivePath, err := b.createArchive(output, name, buildInfo, archives, archiveFiles)
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+
+		output.ArchivePath = archivePath
+		_, _ = fmt.Fprintf(log, "Created %s\n", archivePath)
+		outputs = append(outputs, output)
+		return nil
+	}
+
+	var binaries []*Binary
+	for _, build := range job.Builds {
+		binary, err := b.buildBinary(ctx, build, data, job.OS, job.Arch, job.Variant, outDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build %s for %s: %w", build.ID, platform, err)
+		}
+
+		// Archive each binary separately unless bundling is requested
+		if !archives.Bundle {
+			name := binary.Name
+			if job.OS == "windows" {
+				name = strings.TrimSuffix(name, ".exe")
+			}
+			if err := archive(name, []*Binary{binary}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		binaries = append(binaries, binary)
+	}
+
+	// Bundle all binaries of the target into a single archive
+	if archives.Bundle {
+		if err := archive(filepath.Base(buildInfo.Module), binaries); err != nil {
+			return nil, err
+		}
+	}
+
+	return outputs, nil
+}
+
 // getBuildInfo retrieves module name and version
 func (b *Builder) getBuildInfo() (*BuildInfo, error) {
 	module, err := getModuleName()
@@ -159,25 +533,50 @@ func (b *Builder) getBuildInfo() (*BuildInfo, error) {
 		return nil, fmt.Errorf("failed to get module name: %w", err)
 	}
 
+	buildInfo := &BuildInfo{Module: module}
+
 	version, err := b.git.GetHeadTag()
 	if err != nil {
 		if b.allowDirty {
 			// Use development version when --allow-dirty is specified
 			version = "v0.0.0-dev"
+			buildInfo.IsSnapshot = true
 			fmt.Println("Warning: No git tag found, using development version v0.0.0-dev")
 		} else {
 			return nil, fmt.Errorf("failed to get version: %w", err)
 		}
 	}
+	buildInfo.Version = version
 
-	return &BuildInfo{
-		Module:  module,
-		Version: version,
-	}, nil
+	// Get commit information
+	if buildInfo.Commit, err = b.git.GetCommit(); err != nil {
+		if !b.allowDirty {
+			return nil, err
+		}
+		// Leave commit fields empty in a repository without commits when --allow-dirty is specified
+		fmt.Println("Warning: No commit found, leaving commit information empty")
+	} else {
+		if buildInfo.ShortCommit, err = b.git.GetShortCommit(); err != nil {
+			return nil, err
+		}
+		if buildInfo.CommitDate, err = b.git.GetCommitDate(); err != nil {
+			return nil, err
+		}
+	}
+	if buildInfo.PreviousTag, err = b.git.GetPreviousTag(); err != nil {
+		return nil, err
+	}
+
+	// Get build date
+	if buildInfo.Date, err = util.SourceDate(); err != nil {
+		return nil, err
+	}
+
+	return buildInfo, nil
 }
 
 // generateFormula generates Homebrew Formula
-func (b *Builder) generateFormula(buildInfo *BuildInfo, result *BuildResult) error {
+func (b *Builder) generateFormula(cfg *config.Config, buildInfo *BuildInfo) error {
 	fmt.Println("Generating Homebrew Formula...")
 
 	// Get repository info
@@ -185,42 +584,45 @@ func (b *Builder) generateFormula(buildInfo *BuildInfo, result *BuildResult) err
 	if err != nil {
 		return fmt.Errorf("failed to get repository info: %w", err)
 	}
+	h, err := resolveHosting(cfg, repo)
+	if err != nil {
+		return err
+	}
 
 	// Create artifact information
+	buildIDs := cfg.Brew.Builds
 	var artifacts []formula.Artifact
-	for _, output := range result.Outputs {
-		// Determine archive name
-		var archiveName string
-		if output.OS == "windows" {
-			archiveName = fmt.Sprintf("%s_%s_%s_%s.zip", filepath.Base(buildInfo.Module), result.Version, output.OS, output.Arch)
-		} else {
-			archiveName = fmt.Sprintf("%s_%s_%s_%s.tar.gz", filepath.Base(buildInfo.Module), result.Version, output.OS, output.Arch)
-		}
-		archivePath := filepath.Join("dist", archiveName)
-
-		// Calculate SHA256
-		file, err := os.Open(archivePath)
-		if err != nil {
-			return fmt.Errorf("failed to open file %s: %w", archivePath, err)
+	for _, archive := range b.artifacts.List(artifact.ByType(artifact.TypeArchive)) {
+		// Only use archives that contain every binary installed by the formula
+		var binaries []formula.Binary
+		for _, id := range buildIDs {
+			i := slices.IndexFunc(archive.Binaries, func(binary artifact.Binary) bool { return binary.BuildID == id })
+			if i < 0 {
+				break
+			}
+			binaries = append(binaries, formulaBinary(archive, archive.Binaries[i]))
 		}
-		sha256, err := util.CalculateSHA256(file)
-		_ = file.Close()
-		if err != nil {
-			return fmt.Errorf("failed to calculate SHA256 for %s: %w", archivePath, err)
+		if len(binaries) != len(buildIDs) {
+			continue
 		}
 
 		// Build URL
-		url := fmt.Sprintf("https://github.com/%s/%s/releases/download/%s/%s",
-			repo.Owner, repo.Name, result.Version, archiveName)
+		url := downloadURL(h, repo, buildInfo.Version, archive.Name)
 
 		artifacts = append(artifacts, formula.Artifact{
-			OS:     output.OS,
-			Arch:   output.Arch,
-			URL:    url,
-			SHA256: sha256,
+			OS:       archive.OS,
+			Arch:     archive.Arch,
+			Variant:  archive.Variant,
+			URL:      url,
+			SHA256:   archive.SHA256(),
+			Binaries: binaries,
 		})
 	}
 
+	if len(artifacts) == 0 {
+		return fmt.Errorf("no archive contains all of the builds %v (set archives.bundle or brew.builds)", buildIDs)
+	}
+
 	// Generate Formula
 	f := &formula.Formula{
 		Name:      filepath.Base(buildInfo.Module),
@@ -234,38 +636,69 @@ func (b *Builder) generateFormula(buildInfo *BuildInfo, result *BuildResult) err
 	}
 
 	// Save to file
-	formulaPath := filepath.Join("dist", fmt.Sprintf("%s.rb", f.Name))
+	formulaPath := filepath.Join(b.distDir, fmt.Sprintf("%s.rb", f.Name))
 	if err := os.WriteFile(formulaPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write formula file: %w", err)
 	}
+	if err := b.artifacts.Add(&artifact.Artifact{Type: artifact.TypeFormula, Path: formulaPath}); err != nil {
+		return err
+	}
 
 	fmt.Printf("Created %s\n", formulaPath)
 	return nil
 }
 
+// formulaBinary returns how Homebrew installs a binary from an archive
+func formulaBinary(archive *artifact.Artifact, binary artifact.Binary) formula.Binary {
+	name := strings.TrimSuffix(binary.Name, ".exe")
+
+	switch archive.Extra["format"] {
+	case "binary":
+		// The downloaded file is the binary itself
+		return formula.Binary{Src: archive.Name, Name: name}
+	case "gz":
+		// Homebrew decompresses the file in place
+		return formula.Binary{Src: strings.TrimSuffix(archive.Name, ".gz"), Name: name}
+	default:
+		// Homebrew strips the top-level directory of archives
+		return formula.Binary{Src: binary.Name, Name: name}
+	}
+}
+
 // buildBinary builds a single binary
-func (b *Builder) buildBinary(module, goos, goarch, ldflags string) (*BuildOutput, error) {
+func (b *Builder) buildBinary(ctx context.Context, build config.Build, data map[string]any, goos, goarch, variant, outDir string) (*Binary, error) {
+	// Render build options for the target
+	data = targetTemplateData(data, goos, goarch, variant)
+	opts := build.BuildOptions
+	if err := config.Render(&opts, data); err != nil {
+		return nil, fmt.Errorf("failed to render build options: %w", err)
+	}
+
 	// Determine output file name
-	binaryName := filepath.Base(module)
-	if goos == "windows" {
-		binaryName += ".exe"
+	binaryName, err := renderBinaryName(build, data, goos)
+	if err != nil {
+		return nil, err
 	}
-	binaryPath := filepath.Join("dist", binaryName)
+	binaryPath := filepath.Join(outDir, binaryName)
 
 	// Build command
 	args := []string{"build", "-o", binaryPath}
-
-	// Add ldflags if specified
-	if ldflags != "" {
-		args = append(args, "-ldflags", ldflags)
-	}
+	args = append(args, buildFlags(opts)...)
+	args = append(args, build.Main)
 
 	// Execute command
-	cmd := exec.Command("go", args...)
-	cmd.Env = append(os.Environ(),
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Env = append(os.Environ(), b.env...)
+	for _, env := range opts.Env {
+		cmd.Env = append(cmd.Env, string(env))
+	}
+	cmd.Env = append(cmd.Env,
 		fmt.Sprintf("GOOS=%s", goos),
 		fmt.Sprintf("GOARCH=%s", goarch),
 	)
+	if variant != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", variantEnvName(goarch), variant))
+	}
 
 	// Capture error output
 	var stderr strings.Builder
@@ -275,157 +708,146 @@ func (b *Builder) buildBinary(module, goos, goarch, ldflags string) (*BuildOutpu
 		return nil, fmt.Errorf("go build failed: %w\nstderr: %s", err, stderr.String())
 	}
 
-	return &BuildOutput{
-		OS:         goos,
-		Arch:       goarch,
-		BinaryPath: binaryPath,
+	return &Binary{
+		BuildID: build.ID,
+		Name:    binaryName,
+		Path:    binaryPath,
 	}, nil
 }
 
-// getModuleName retrieves module name from go.mod
-func getModuleName() (string, error) {
-	file, err := os.Open("go.mod")
+// renderBinaryName renders the file name of a binary for the target in data
+func renderBinaryName(build config.Build, data map[string]any, goos string) (string, error) {
+	binaryName, err := build.Binary.Execute(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to open go.mod: %w", err)
+		return "", fmt.Errorf("failed to render binary name: %w", err)
 	}
-	defer func() { _ = file.Close() }()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if after, ok := strings.CutPrefix(line, "module "); ok {
-			module := after
-			return strings.TrimSpace(module), nil
-		}
+	if binaryName == "" || strings.ContainsAny(binaryName, `/\`) {
+		return "", fmt.Errorf("invalid binary name: %q", binaryName)
 	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	if goos == "windows" && !strings.HasSuffix(binaryName, ".exe") {
+		binaryName += ".exe"
 	}
-
-	return "", fmt.Errorf("module name not found in go.mod")
+	return binaryName, nil
 }
 
-// createArchive creates an archive from build output
-func (b *Builder) createArchive(output *BuildOutput) (string, error) {
-	// Extract module name from binary path
-	binaryName := filepath.Base(output.BinaryPath)
-	moduleName := strings.TrimSuffix(binaryName, filepath.Ext(binaryName))
+// variantEnvName returns the environment variable that selects the micro-architecture of goarch
+func variantEnvName(goarch string) string {
+	switch goarch {
+	case "arm":
+		return "GOARM"
+	case "amd64":
+		return "GOAMD64"
+	case "arm64":
+		return "GOARM64"
+	case "386":
+		return "GO386"
+	case "mips64", "mips64le":
+		return "GOMIPS64"
+	default:
+		return "GOMIPS"
+	}
+}
 
-	// Get version from git
-	version, err := b.git.GetHeadTag()
-	if err != nil {
-		if b.allowDirty {
-			// Use development version when --allow-dirty is specified
-			version = "v0.0.0-dev"
-		} else {
-			return "", fmt.Errorf("failed to get version: %w", err)
+// archName returns the architecture name including its variant (e.g. armv7, amd64v3)
+func archName(arch, variant string) string {
+	switch {
+	case variant == "":
+		return arch
+	case arch == "arm":
+		return arch + "v" + variant
+	case arch == "amd64":
+		// v1 is the baseline
+		if variant == "v1" {
+			return arch
 		}
+		return arch + variant
+	default:
+		return arch + "_" + variant
 	}
+}
 
-	// Determine archive name
-	var archiveName string
-	if output.OS == "windows" {
-		archiveName = fmt.Sprintf("%s_%s_%s_%s.zip", moduleName, version, output.OS, output.Arch)
-		return b.createZip(output.BinaryPath, archiveName, moduleName, version, output.OS, output.Arch)
-	} else {
-		archiveName = fmt.Sprintf("%s_%s_%s_%s.tar.gz", moduleName, version, output.OS, output.Arch)
-		return b.createTarGz(output.BinaryPath, archiveName, moduleName, version, output.OS, output.Arch)
-	}
+// platformName returns the OS/arch pair including the variant (e.g. linux/armv7)
+func platformName(goos, arch, variant string) string {
+	return goos + "/" + archName(arch, variant)
 }
 
-// createTarGz creates a tar.gz archive
-func (b *Builder) createTarGz(src, archiveName, moduleName, version, osName, arch string) (string, error) {
-	archivePath := filepath.Join("dist", archiveName)
+// buildFlags converts build options to go build flags.
+// Binaries are reproducible by default: -trimpath is on unless disabled, the build ID is cleared
+// and VCS stamping is off unless -buildvcs is set, since files written to dist during the build
+// would make the stamped vcs.modified depend on timing.
+func buildFlags(opts config.BuildOptions) []string {
+	var args []string
 
-	// Create archive file
-	file, err := os.Create(archivePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create archive file: %w", err)
+	if opts.Trimpath == nil || *opts.Trimpath {
+		args = append(args, "-trimpath")
 	}
-	defer func() { _ = file.Close() }()
-
-	// gzip writer
-	gzipWriter := gzip.NewWriter(file)
-	defer func() { _ = gzipWriter.Close() }()
-
-	// tar writer
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer func() { _ = tarWriter.Close() }()
-
-	// Open source file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return "", fmt.Errorf("failed to open source file: %w", err)
+	if !setsBuildVCS(opts) {
+		args = append(args, "-buildvcs=false")
 	}
-	defer func() { _ = srcFile.Close() }()
-
-	// Get file info
-	srcInfo, err := srcFile.Stat()
-	if err != nil {
-		return "", fmt.Errorf("failed to get file info: %w", err)
+	if opts.Buildmode != "" {
+		args = append(args, "-buildmode", string(opts.Buildmode))
 	}
-
-	// Directory structure in archive
-	dirName := fmt.Sprintf("%s_%s_%s_%s", moduleName, version, osName, arch)
-	binaryNameInArchive := filepath.Join(dirName, moduleName)
-
-	// Create tar header
-	header := &tar.Header{
-		Name: binaryNameInArchive,
-		Mode: 0755,
-		Size: srcInfo.Size(),
+	if len(opts.Tags) > 0 {
+		tags := make([]string, len(opts.Tags))
+		for i, tag := range opts.Tags {
+			tags[i] = string(tag)
+		}
+		args = append(args, "-tags", strings.Join(tags, ","))
 	}
-
-	// Write header
-	if err := tarWriter.WriteHeader(header); err != nil {
-		return "", fmt.Errorf("failed to write tar header: %w", err)
+	ldflags := string(opts.Ldflags)
+	if !strings.Contains(ldflags, "-buildid") {
+		ldflags = strings.TrimSpace(ldflags + " -buildid=")
 	}
-
-	// Copy file content
-	if _, err := io.Copy(tarWriter, srcFile); err != nil {
-		return "", fmt.Errorf("failed to write file to tar: %w", err)
+	args = append(args, "-ldflags", ldflags)
+	if opts.Gcflags != "" {
+		args = append(args, "-gcflags", string(opts.Gcflags))
+	}
+	if opts.Asmflags != "" {
+		args = append(args, "-asmflags", string(opts.Asmflags))
+	}
+	for _, flag := range opts.Flags {
+		args = append(args, string(flag))
 	}
 
-	return archivePath, nil
+	return args
 }
 
-// createZip creates a zip archive
-func (b *Builder) createZip(src, archiveName, moduleName, version, osName, arch string) (string, error) {
-	archivePath := filepath.Join("dist", archiveName)
-
-	// Create archive file
-	file, err := os.Create(archivePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create archive file: %w", err)
+// setsBuildVCS reports whether -buildvcs is set in the flags or in GOFLAGS of the options or the environment
+func setsBuildVCS(opts config.BuildOptions) bool {
+	for _, flag := range opts.Flags {
+		if strings.HasPrefix(strings.TrimLeft(string(flag), "-"), "buildvcs") {
+			return true
+		}
 	}
-	defer func() { _ = file.Close() }()
-
-	// zip writer
-	zipWriter := zip.NewWriter(file)
-	defer func() { _ = zipWriter.Close() }()
+	goflags := os.Getenv("GOFLAGS")
+	for _, env := range opts.Env {
+		if value, ok := strings.CutPrefix(string(env), "GOFLAGS="); ok {
+			goflags = value
+		}
+	}
+	return strings.Contains(goflags, "-buildvcs")
+}
 
-	// Open source file
-	srcFile, err := os.Open(src)
+// getModuleName retrieves module name from go.mod
+func getModuleName() (string, error) {
+	file, err := os.Open("go.mod")
 	if err != nil {
-		return "", fmt.Errorf("failed to open source file: %w", err)
+		return "", fmt.Errorf("failed to open go.mod: %w", err)
 	}
-	defer func() { _ = srcFile.Close() }()
-
-	// Directory structure in archive
-	dirName := fmt.Sprintf("%s_%s_%s_%s", moduleName, version, osName, arch)
-	binaryNameInArchive := filepath.Join(dirName, moduleName+".exe")
+	defer func() { _ = file.Close() }()
 
-	// Create zip entry
-	writer, err := zipWriter.Create(binaryNameInArchive)
-	if err != nil {
-		return "", fmt.Errorf("failed to create zip entry: %w", err)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if after, ok := strings.CutPrefix(line, "module "); ok {
+			module := after
+			return strings.TrimSpace(module), nil
+		}
 	}
 
-	// Copy file content
-	if _, err := io.Copy(writer, srcFile); err != nil {
-		return "", fmt.Errorf("failed to write file to zip: %w", err)
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
 	}
 
-	return archivePath, nil
+	return "", fmt.Errorf("module name not found in go.mod")
 }
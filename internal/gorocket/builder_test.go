@@ -0,0 +1,290 @@
+package gorocket
+
+import (
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_defaultBrewBuilds(t *testing.T) {
+	builds := []config.Build{{ID: "server"}, {ID: "cli"}}
+
+	tests := []struct {
+		name     string
+		archives config.Archives
+		builds   []config.Build
+		expected []string
+	}{
+		{
+			name:     "single build",
+			builds:   builds[:1],
+			expected: []string{"server"},
+		},
+		{
+			name:     "unbundled builds install the first build",
+			builds:   builds,
+			expected: []string{"server"},
+		},
+		{
+			name:     "bundled builds install every build",
+			archives: config.Archives{Bundle: true},
+			builds:   builds,
+			expected: []string{"server", "cli"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, defaultBrewBuilds(tt.archives, tt.builds))
+		})
+	}
+}
+
+// jobNames returns the platform names of jobs with the ids of their builds
+func jobNames(jobs []*buildJob) []string {
+	var names []string
+	for _, job := range jobs {
+		name := platformName(job.OS, job.Arch, job.Variant)
+		for _, build := range job.Builds {
+			name += " " + build.ID
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func Test_expandBuildJobs(t *testing.T) {
+	targets := []config.Target{
+		{OS: "linux", Arch: []string{"amd64", "arm"}, Goarm: []string{"6", "7"}},
+		{OS: "darwin", Arch: []string{"amd64", "arm64"}},
+		{OS: "windows", Arch: []string{"amd64"}},
+	}
+
+	tests := []struct {
+		name     string
+		builds   []config.Build
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "every target",
+			builds:   []config.Build{{ID: "app", Targets: targets}},
+			expected: []string{"linux/amd64 app", "linux/armv6 app", "linux/armv7 app", "darwin/amd64 app", "darwin/arm64 app", "windows/amd64 app"},
+		},
+		{
+			name: "ignore by os and arch",
+			builds: []config.Build{{ID: "app", Targets: targets, Ignore: []config.Ignore{
+				{OS: "darwin", Arch: "amd64"},
+				{OS: "windows"},
+			}}},
+			expected: []string{"linux/amd64 app", "linux/armv6 app", "linux/armv7 app", "darwin/arm64 app"},
+		},
+		{
+			name:     "ignore by variant",
+			builds:   []config.Build{{ID: "app", Targets: targets, Ignore: []config.Ignore{{Arch: "arm", Variant: "6"}}}},
+			expected: []string{"linux/amd64 app", "linux/armv7 app", "darwin/amd64 app", "darwin/arm64 app", "windows/amd64 app"},
+		},
+		{
+			name:     "ignore with globs",
+			builds:   []config.Build{{ID: "app", Targets: targets, Ignore: []config.Ignore{{OS: "win*"}, {Arch: "arm*"}}}},
+			expected: []string{"linux/amd64 app", "darwin/amd64 app"},
+		},
+		{
+			name: "builds are grouped by target",
+			builds: []config.Build{
+				{ID: "server", Targets: targets[:1]},
+				{ID: "cli", Targets: targets, Ignore: []config.Ignore{{Arch: "arm"}}},
+			},
+			expected: []string{"linux/amd64 server cli", "linux/armv6 server", "linux/armv7 server", "darwin/amd64 cli", "darwin/arm64 cli", "windows/amd64 cli"},
+		},
+		{
+			name:    "invalid pattern",
+			builds:  []config.Build{{ID: "app", Targets: targets, Ignore: []config.Ignore{{OS: "["}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := expandBuildJobs(tt.builds)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, jobNames(jobs))
+		})
+	}
+}
+
+func Test_variantEnvName(t *testing.T) {
+	tests := []struct {
+		goarch   string
+		expected string
+	}{
+		{goarch: "arm", expected: "GOARM"},
+		{goarch: "amd64", expected: "GOAMD64"},
+		{goarch: "arm64", expected: "GOARM64"},
+		{goarch: "386", expected: "GO386"},
+		{goarch: "mips", expected: "GOMIPS"},
+		{goarch: "mipsle", expected: "GOMIPS"},
+		{goarch: "mips64", expected: "GOMIPS64"},
+		{goarch: "mips64le", expected: "GOMIPS64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goarch, func(t *testing.T) {
+			assert.Equal(t, tt.expected, variantEnvName(tt.goarch))
+		})
+	}
+}
+
+func Test_archName(t *testing.T) {
+	tests := []struct {
+		arch     string
+		variant  string
+		expected string
+	}{
+		{arch: "amd64", expected: "amd64"},
+		{arch: "arm", variant: "6", expected: "armv6"},
+		{arch: "arm", variant: "7", expected: "armv7"},
+		{arch: "amd64", variant: "v1", expected: "amd64"},
+		{arch: "amd64", variant: "v3", expected: "amd64v3"},
+		{arch: "arm64", variant: "v8.2", expected: "arm64_v8.2"},
+		{arch: "386", variant: "softfloat", expected: "386_softfloat"},
+		{arch: "mipsle", variant: "hardfloat", expected: "mipsle_hardfloat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch+" "+tt.variant, func(t *testing.T) {
+			assert.Equal(t, tt.expected, archName(tt.arch, tt.variant))
+			assert.Equal(t, "linux/"+tt.expected, platformName("linux", tt.arch, tt.variant))
+		})
+	}
+}
+
+func Test_filterJobs(t *testing.T) {
+	jobs, err := expandBuildJobs([]config.Build{{ID: "app", Targets: []config.Target{
+		{OS: "linux", Arch: []string{"amd64", "arm"}, Goarm: []string{"6", "7"}},
+		{OS: "darwin", Arch: []string{"amd64", "arm64"}},
+	}}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "exact target",
+			patterns: []string{"linux/amd64"},
+			expected: []string{"linux/amd64 app"},
+		},
+		{
+			name:     "glob",
+			patterns: []string{"darwin/*"},
+			expected: []string{"darwin/amd64 app", "darwin/arm64 app"},
+		},
+		{
+			name:     "arch without variant matches every variant",
+			patterns: []string{"linux/arm"},
+			expected: []string{"linux/armv6 app", "linux/armv7 app"},
+		},
+		{
+			name:     "arch with variant",
+			patterns: []string{"linux/armv7"},
+			expected: []string{"linux/armv7 app"},
+		},
+		{
+			name:     "several patterns keep the matrix order",
+			patterns: []string{"darwin/arm64", "linux/amd64"},
+			expected: []string{"linux/amd64 app", "darwin/arm64 app"},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"windows/*"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid pattern",
+			patterns: []string{"["},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := filterJobs(jobs, tt.patterns)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, jobNames(filtered))
+		})
+	}
+}
+
+func Test_buildFlags(t *testing.T) {
+	disabled := false
+
+	tests := []struct {
+		name     string
+		opts     config.BuildOptions
+		goflags  string
+		expected []string
+	}{
+		{
+			name:     "reproducible by default",
+			expected: []string{"-trimpath", "-buildvcs=false", "-ldflags", "-buildid="},
+		},
+		{
+			name: "every option",
+			opts: config.BuildOptions{
+				Tags:      []config.Template{"netgo", "osusergo"},
+				Ldflags:   "-s -w",
+				Gcflags:   "all=-N",
+				Asmflags:  "all=-trimpath",
+				Trimpath:  &disabled,
+				Buildmode: "pie",
+				Flags:     []config.Template{"-v"},
+			},
+			expected: []string{"-buildvcs=false", "-buildmode", "pie", "-tags", "netgo,osusergo", "-ldflags", "-s -w -buildid=", "-gcflags", "all=-N", "-asmflags", "all=-trimpath", "-v"},
+		},
+		{
+			name:     "build ID in ldflags",
+			opts:     config.BuildOptions{Ldflags: "-buildid=abc"},
+			expected: []string{"-trimpath", "-buildvcs=false", "-ldflags", "-buildid=abc"},
+		},
+		{
+			name:     "buildvcs in the flags",
+			opts:     config.BuildOptions{Flags: []config.Template{"-buildvcs=true"}},
+			expected: []string{"-trimpath", "-ldflags", "-buildid=", "-buildvcs=true"},
+		},
+		{
+			name:     "buildvcs in GOFLAGS of the options",
+			opts:     config.BuildOptions{Env: []config.Template{"GOFLAGS=-mod=mod -buildvcs=auto"}},
+			expected: []string{"-trimpath", "-ldflags", "-buildid="},
+		},
+		{
+			name:     "buildvcs in GOFLAGS of the environment",
+			goflags:  "-buildvcs=true",
+			expected: []string{"-trimpath", "-ldflags", "-buildid="},
+		},
+		{
+			name:     "GOFLAGS of the options replace the environment",
+			opts:     config.BuildOptions{Env: []config.Template{"GOFLAGS=-mod=mod"}},
+			goflags:  "-buildvcs=true",
+			expected: []string{"-trimpath", "-buildvcs=false", "-ldflags", "-buildid="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOFLAGS", tt.goflags)
+			assert.Equal(t, tt.expected, buildFlags(tt.opts))
+		})
+	}
+}
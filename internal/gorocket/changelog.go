@@ -0,0 +1,127 @@
+package gorocket
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/koki-develop/gorocket/internal/changelog"
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/koki-develop/gorocket/internal/github"
+)
+
+// noreplyPattern matches GitHub noreply addresses (e.g. 12345+octocat@users.noreply.github.com)
+var noreplyPattern = regexp.MustCompile(`^(?:\d+\+)?([^@]+)@users\.noreply\.github\.com$`)
+
+// Changelogger provides changelog generation
+type Changelogger struct {
+	configPath string
+	git        *git.Client
+	token      string
+	verbose    bool
+}
+
+// NewChangelogger creates a new Changelogger instance.
+// Without a token, author handles are only resolved from GitHub noreply addresses.
+func NewChangelogger(configPath string, token string, verbose bool) *Changelogger {
+	return &Changelogger{
+		configPath: configPath,
+		git:        git.New(),
+		token:      token,
+		verbose:    verbose,
+	}
+}
+
+// Generate generates the changelog of the commits since the previous tag
+func (c *Changelogger) Generate() (string, error) {
+	// Load config file
+	cfg, err := config.LoadConfig(c.configPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Changelog.Disable {
+		return "", nil
+	}
+
+	opts := changelog.Options{
+		Sort:    cfg.Changelog.Sort,
+		Include: cfg.Changelog.Filters.Include,
+		Exclude: cfg.Changelog.Filters.Exclude,
+	}
+	switch cfg.Changelog.Group {
+	case "", "conventional":
+		opts.Group = true
+	case "none":
+	default:
+		return "", fmt.Errorf("invalid changelog.group: %s (expected conventional or none)", cfg.Changelog.Group)
+	}
+	links := cfg.Changelog.Links == nil || *cfg.Changelog.Links
+	authors := cfg.Changelog.Authors == nil || *cfg.Changelog.Authors
+
+	// Get commits since the previous tag
+	previousTag, err := c.git.GetPreviousTag()
+	if err != nil {
+		return "", err
+	}
+	commits, err := c.git.GetLog(previousTag, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	// Links are only available for GitHub, GitLab and Gitea repositories
+	repo, err := c.git.GetRepository()
+	if err != nil {
+		repo = nil
+	}
+	h, err := resolveHosting(cfg, repo)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve GitHub logins of commit authors
+	logins := map[string]string{}
+	if authors && repo != nil && h.Backend == backendGitHub && c.token != "" && previousTag != "" && len(commits) > 0 {
+		gh, err := newGitHubClient(cfg, h, c.token, c.verbose, os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if logins, err = gh.GetCommitAuthors(github.GetCommitAuthorsParams{
+			Owner: repo.Owner,
+			Repo:  repo.Name,
+			Base:  previousTag,
+			Head:  commits[0].Hash,
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to get commit authors: %v\n", err)
+			logins = map[string]string{}
+		}
+	}
+
+	entries := make([]changelog.Commit, 0, len(commits))
+	for _, commit := range commits {
+		entry := changelog.Commit{
+			ShortHash: commit.ShortHash,
+			Subject:   commit.Subject,
+		}
+		if links && repo != nil {
+			entry.URL = commitURL(h, repo, commit.Hash)
+		}
+		if authors {
+			entry.Author = authorHandle(commit, logins)
+		}
+		entries = append(entries, entry)
+	}
+
+	return changelog.Generate(entries, opts)
+}
+
+// authorHandle returns the GitHub handle of a commit author, falling back to the author name
+func authorHandle(commit git.Commit, logins map[string]string) string {
+	if login, ok := logins[commit.Hash]; ok {
+		return "@" + login
+	}
+	if m := noreplyPattern.FindStringSubmatch(commit.AuthorEmail); m != nil {
+		return "@" + m[1]
+	}
+	return commit.AuthorName
+}
@@ -0,0 +1,70 @@
+package gorocket
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/koki-develop/gorocket/internal/util"
+)
+
+// validateChecksumAlgorithm checks checksum.algorithm, which is only used after every target is built
+func validateChecksumAlgorithm(algorithm string) error {
+	if algorithm != "" && !slices.Contains(util.HashAlgorithms(), algorithm) {
+		return fmt.Errorf("unsupported checksum algorithm: %s (expected one of %s)", algorithm, strings.Join(util.HashAlgorithms(), ", "))
+	}
+	return nil
+}
+
+// writeChecksums writes <project>_<version>_checksums.txt for the archives
+// and optionally a sidecar checksum file for each of them
+func (b *Builder) writeChecksums(buildInfo *BuildInfo, algorithm string, sidecar bool) error {
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+
+	var lines []string
+	for _, archive := range b.artifacts.List(artifact.ByType(artifact.TypeArchive)) {
+		// Reuse the checksum of the registry when possible
+		sum := archive.SHA256()
+		if algorithm != "sha256" {
+			file, err := os.Open(archive.Path)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s: %w", archive.Path, err)
+			}
+			sum, err = util.CalculateHash(file, algorithm)
+			_ = file.Close()
+			if err != nil {
+				return fmt.Errorf("failed to calculate checksum for %s: %w", archive.Path, err)
+			}
+		}
+
+		// Use the same format as sha256sum and friends
+		line := fmt.Sprintf("%s  %s\n", sum, archive.Name)
+		lines = append(lines, line)
+
+		if sidecar {
+			sidecarPath := fmt.Sprintf("%s.%s", archive.Path, algorithm)
+			if err := os.WriteFile(sidecarPath, []byte(line), 0644); err != nil {
+				return fmt.Errorf("failed to write checksum file: %w", err)
+			}
+			if err := b.artifacts.Add(&artifact.Artifact{Type: artifact.TypeChecksum, Path: sidecarPath}); err != nil {
+				return err
+			}
+		}
+	}
+
+	checksumsPath := filepath.Join(b.distDir, fmt.Sprintf("%s_%s_checksums.txt", filepath.Base(buildInfo.Module), buildInfo.Version))
+	if err := os.WriteFile(checksumsPath, []byte(strings.Join(lines, "")), 0644); err != nil {
+		return fmt.Errorf("failed to write checksums file: %w", err)
+	}
+	if err := b.artifacts.Add(&artifact.Artifact{Type: artifact.TypeChecksum, Path: checksumsPath}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s\n", checksumsPath)
+	return nil
+}
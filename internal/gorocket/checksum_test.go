@@ -0,0 +1,100 @@
+package gorocket
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateChecksumAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		wantErr   string
+	}{
+		{algorithm: ""},
+		{algorithm: "sha256"},
+		{algorithm: "sha3-256"},
+		{algorithm: "crc32"},
+		{algorithm: "md5", wantErr: "unsupported checksum algorithm: md5 (expected one of blake2b, crc32, sha1, sha256, sha3-256, sha512)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			err := validateChecksumAlgorithm(tt.algorithm)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_writeChecksums(t *testing.T) {
+	const (
+		helloSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+		helloSHA512 = "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+		emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		emptySHA512 = "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		sidecar   bool
+		expected  string
+		sidecars  map[string]string // sidecar file name to content
+	}{
+		{
+			name:     "sha256 by default",
+			expected: helloSHA256 + "  app_linux_amd64.tar.gz\n" + emptySHA256 + "  app_windows_amd64.zip\n",
+		},
+		{
+			name:      "sidecar files",
+			algorithm: "sha512",
+			sidecar:   true,
+			expected:  helloSHA512 + "  app_linux_amd64.tar.gz\n" + emptySHA512 + "  app_windows_amd64.zip\n",
+			sidecars: map[string]string{
+				"app_linux_amd64.tar.gz.sha512": helloSHA512 + "  app_linux_amd64.tar.gz\n",
+				"app_windows_amd64.zip.sha512":  emptySHA512 + "  app_windows_amd64.zip\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			b := &Builder{distDir: dir, artifacts: artifact.New()}
+			for name, content := range map[string]string{"app_linux_amd64.tar.gz": "hello world", "app_windows_amd64.zip": ""} {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+			}
+			require.NoError(t, b.artifacts.Add(&artifact.Artifact{Type: artifact.TypeArchive, Path: filepath.Join(dir, "app_linux_amd64.tar.gz"), OS: "linux", Arch: "amd64"}))
+			require.NoError(t, b.artifacts.Add(&artifact.Artifact{Type: artifact.TypeArchive, Path: filepath.Join(dir, "app_windows_amd64.zip"), OS: "windows", Arch: "amd64"}))
+
+			require.NoError(t, b.writeChecksums(&BuildInfo{Module: "github.com/owner/app", Version: "v1.0.0"}, tt.algorithm, tt.sidecar))
+
+			// Lines use the sha256sum format in the order of the archives
+			content, err := os.ReadFile(filepath.Join(dir, "app_v1.0.0_checksums.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(content))
+
+			var names []string
+			for _, a := range b.artifacts.List(artifact.ByType(artifact.TypeChecksum)) {
+				names = append(names, a.Name)
+			}
+			expectedNames := []string{"app_v1.0.0_checksums.txt"}
+			for name, expected := range tt.sidecars {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				assert.Equal(t, expected, string(content))
+				expectedNames = append(expectedNames, name)
+			}
+			assert.ElementsMatch(t, expectedNames, names)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package gorocket
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ttyRefreshInterval = 200 * time.Millisecond // Redraw interval on a terminal
+	logRefreshInterval = 10 * time.Second       // Log interval in CI
+)
+
+// uploadProgress reports the progress of concurrent asset uploads.
+// On a terminal the state of every asset is redrawn in place, otherwise progress is logged line by line.
+type uploadProgress struct {
+	out io.Writer
+	tty bool
+
+	mu      sync.Mutex
+	uploads []*uploadState
+	lines   int // Number of lines drawn on the terminal
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// uploadState is the progress of a single asset
+type uploadState struct {
+	name     string
+	total    int64
+	written  int64
+	started  time.Time
+	elapsed  time.Duration
+	finished bool
+	err      error
+}
+
+// newUploadProgress creates a new uploadProgress writing to out
+func newUploadProgress(out io.Writer, tty bool) *uploadProgress {
+	return &uploadProgress{
+		out:  out,
+		tty:  tty,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// add registers an asset to report before the uploads start
+func (p *uploadProgress) add(name string, size int64) *uploadState {
+	u := &uploadState{name: name, total: size}
+	p.uploads = append(p.uploads, u)
+	return u
+}
+
+// start reports the progress periodically until close is called
+func (p *uploadProgress) start() {
+	interval := logRefreshInterval
+	if p.tty {
+		interval = ttyRefreshInterval
+		p.draw()
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if p.tty {
+					p.draw()
+				} else {
+					p.log()
+				}
+			}
+		}
+	}()
+}
+
+// close stops reporting and prints the final state
+func (p *uploadProgress) close() {
+	close(p.stop)
+	<-p.done
+	if p.tty {
+		p.draw()
+	}
+}
+
+// update records the bytes sent for an asset. Retries restart from 0.
+func (p *uploadProgress) update(u *uploadState, written, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if written == 0 {
+		if !p.tty && u.started.IsZero() {
+			_, _ = fmt.Fprintf(p.out, "Uploading %s (%s)...\n", u.name, formatSize(total))
+		}
+		u.started = time.Now()
+	}
+	u.written = written
+	u.total = total
+}
+
+// finish records the result of an asset upload
+func (p *uploadProgress) finish(u *uploadState, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u.finished = true
+	u.err = err
+	if !u.started.IsZero() {
+		u.elapsed = time.Since(u.started)
+	}
+	if !p.tty && err == nil {
+		_, _ = fmt.Fprintf(p.out, "Uploaded %s (%s)\n", u.name, u.status())
+	}
+}
+
+// Write prints log output above the progress.
+// On a terminal the progress is erased first and redrawn on the next refresh.
+func (p *uploadProgress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty && p.lines > 0 {
+		// Move the cursor back to the first line and clear the rest of the screen
+		if _, err := fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.lines); err != nil {
+			return 0, err
+		}
+		p.lines = 0
+	}
+	return p.out.Write(b)
+}
+
+// draw redraws the state of every asset in place
+func (p *uploadProgress) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	width := 0
+	for _, u := range p.uploads {
+		width = max(width, len(u.name))
+	}
+
+	var b strings.Builder
+	if p.lines > 0 {
+		// Move the cursor back to the first line
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, u := range p.uploads {
+		fmt.Fprintf(&b, "\x1b[2K  %-*s  %s\n", width, u.name, u.status())
+	}
+	p.lines = len(p.uploads)
+
+	_, _ = io.WriteString(p.out, b.String())
+}
+
+// log prints a line for every asset that is being uploaded
+func (p *uploadProgress) log() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, u := range p.uploads {
+		if u.started.IsZero() || u.finished {
+			continue
+		}
+		_, _ = fmt.Fprintf(p.out, "Uploading %s: %s\n", u.name, u.status())
+	}
+}
+
+// status describes the progress of the upload
+func (u *uploadState) status() string {
+	switch {
+	case u.err != nil:
+		return "failed"
+	case u.finished:
+		return fmt.Sprintf("%s in %s, %s", formatSize(u.total), u.elapsed.Round(100*time.Millisecond), formatRate(u.total, u.elapsed))
+	case u.started.IsZero():
+		return "waiting"
+	}
+
+	percent := 100
+	if u.total > 0 {
+		percent = int(u.written * 100 / u.total)
+	}
+	return fmt.Sprintf("%s / %s  %3d%%  %s", formatSize(u.written), formatSize(u.total), percent, formatRate(u.written, time.Since(u.started)))
+}
+
+// formatRate formats a transfer rate in a human-readable form
+func formatRate(size int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "-"
+	}
+	return formatSize(int64(float64(size)/elapsed.Seconds())) + "/s"
+}
+
+// logWriter forwards log output to a writer that can be replaced while the output is in use
+type logWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// set replaces the writer that receives the output
+func (l *logWriter) set(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w = w
+}
+
+func (l *logWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
@@ -0,0 +1,96 @@
+package gorocket
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_uploadProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := newUploadProgress(&out, false)
+	app := p.add("app.tar.gz", 2048)
+	checksums := p.add("checksums.txt", 100)
+	assert.Equal(t, "waiting", app.status())
+
+	// Retries restart from 0 without logging the start again
+	p.update(app, 0, 2048)
+	p.update(app, 1024, 2048)
+	p.update(app, 0, 2048)
+	p.update(app, 1024, 2048)
+	assert.Equal(t, "Uploading app.tar.gz (2.0 KiB)...\n", out.String())
+	assert.Regexp(t, `^1\.0 KiB / 2\.0 KiB   50%  `, app.status())
+
+	p.update(app, 2048, 2048)
+	p.finish(app, nil)
+	assert.Regexp(t, `Uploaded app\.tar\.gz \(2\.0 KiB in .+\)\n$`, out.String())
+	assert.Regexp(t, `^2\.0 KiB in `, app.status())
+
+	// Failures are reported by the caller
+	out.Reset()
+	p.update(checksums, 0, 100)
+	p.finish(checksums, errors.New("failed"))
+	assert.Equal(t, "Uploading checksums.txt (100 B)...\n", out.String())
+	assert.Equal(t, "failed", checksums.status())
+}
+
+func Test_uploadState_status(t *testing.T) {
+	started := time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name     string
+		state    *uploadState
+		expected string
+	}{
+		{
+			name:     "waiting",
+			state:    &uploadState{total: 100},
+			expected: `^waiting$`,
+		},
+		{
+			name:     "uploading",
+			state:    &uploadState{total: 400, written: 100, started: started},
+			expected: `^100 B / 400 B   25%  \d+ B/s$`,
+		},
+		{
+			name:     "empty file",
+			state:    &uploadState{started: started},
+			expected: `^0 B / 0 B  100%  0 B/s$`,
+		},
+		{
+			name:     "finished",
+			state:    &uploadState{total: 2048, written: 2048, started: started, elapsed: 2 * time.Second, finished: true},
+			expected: `^2\.0 KiB in 2s, 1\.0 KiB/s$`,
+		},
+		{
+			name:     "failed",
+			state:    &uploadState{total: 100, started: started, finished: true, err: errors.New("failed")},
+			expected: `^failed$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Regexp(t, tt.expected, tt.state.status())
+		})
+	}
+}
+
+func Test_uploadProgress_Write(t *testing.T) {
+	var out bytes.Buffer
+	p := newUploadProgress(&out, true)
+	p.add("app.tar.gz", 100)
+	p.draw()
+
+	// Log output erases the progress, which is drawn again below it
+	out.Reset()
+	_, _ = p.Write([]byte("Deleting existing app.tar.gz...\n"))
+	assert.Equal(t, "\x1b[1A\x1b[JDeleting existing app.tar.gz...\n", out.String())
+
+	out.Reset()
+	p.draw()
+	assert.Equal(t, "\x1b[2K  app.tar.gz  waiting\n", out.String())
+}
@@ -1,165 +1,591 @@
 package gorocket
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/koki-develop/gorocket/internal/backend"
 	"github.com/koki-develop/gorocket/internal/config"
 	"github.com/koki-develop/gorocket/internal/git"
-	"github.com/koki-develop/gorocket/internal/github"
+	"github.com/koki-develop/gorocket/internal/semver"
+	"github.com/koki-develop/gorocket/internal/util"
 )
 
 // ReleaseParams contains options for the release command
 type ReleaseParams struct {
-	Draft bool
-	Clean bool
+	Draft             bool
+	FailIfExists      bool
+	ReleaseNotes      string
+	Clean             bool
+	Parallelism       int
+	UploadParallelism int
 }
 
+// PublishParams contains options for the publish command
+type PublishParams struct {
+	Draft             bool
+	FailIfExists      bool
+	ReleaseNotes      string // Path to a file that overrides the generated changelog
+	UploadParallelism int    // Number of assets to upload concurrently
+}
+
+// defaultUploadParallelism is the number of assets uploaded concurrently by default
+const defaultUploadParallelism = 4
+
 // Releaser provides release functionality
 type Releaser struct {
 	configPath string
 	git        *git.Client
-	github     *github.Client
+	backend    backend.Backend
 	builder    *Builder
+	changelog  *Changelogger
+	log        *logWriter // Log output of the backend, routed through the upload progress while uploading
 }
 
-// NewReleaser creates a new Releaser instance
-func NewReleaser(configPath string, token string) (*Releaser, error) {
-	if token == "" {
-		token = os.Getenv("GITHUB_TOKEN")
-		if token == "" {
-			return nil, fmt.Errorf("GitHub token is required (use --token or GITHUB_TOKEN env var)")
-		}
+// NewReleaser creates a new Releaser instance for the backend hosting the repository
+func NewReleaser(configPath string, tokens Tokens, verbose bool) (*Releaser, error) {
+	cfg, err := config.LoadConfig(configPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// The remote is only used to detect the backend here
+	g := git.New()
+	repo, err := g.GetRepository()
+	if err != nil {
+		repo = nil
+	}
+	h, err := resolveHosting(cfg, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &logWriter{w: os.Stderr}
+	b, err := newBackend(cfg, h, tokens, verbose, log)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Releaser{
 		configPath: configPath,
-		git:        git.New(),
-		github:     github.New(token),
+		git:        g,
+		backend:    b,
 		builder:    NewBuilder(configPath),
+		changelog:  NewChangelogger(configPath, cmp.Or(tokens.GitHub, os.Getenv("GITHUB_TOKEN")), verbose),
+		log:        log,
 	}, nil
 }
 
-// Release creates a GitHub release
+// Release builds the artifacts and publishes them
 func (r *Releaser) Release(params ReleaseParams) error {
 	// First build the binaries
-	if err := r.builder.Build(BuildParams{Clean: params.Clean}); err != nil {
+	if err := r.builder.Build(BuildParams{Clean: params.Clean, Parallelism: params.Parallelism}); err != nil {
 		return fmt.Errorf("failed to build: %w", err)
 	}
 
-	// Get repository info
-	repo, err := r.git.GetRepository()
+	return r.Publish(PublishParams{
+		Draft:             params.Draft,
+		FailIfExists:      params.FailIfExists,
+		ReleaseNotes:      params.ReleaseNotes,
+		UploadParallelism: params.UploadParallelism,
+	})
+}
+
+// Publish creates a release from the artifacts of a previous build
+func (r *Releaser) Publish(params PublishParams) error {
+	// Load artifact manifest
+	artifacts, metadata, err := artifact.Load(r.builder.distDir)
 	if err != nil {
-		return fmt.Errorf("failed to get repository info: %w", err)
+		return fmt.Errorf("failed to load artifact manifest (run gorocket build first): %w", err)
 	}
 
-	// Get version tag
+	// Refuse to publish artifacts built from another commit
+	if metadata.IsSnapshot {
+		return fmt.Errorf("cannot publish a snapshot build (%s)", metadata.Tag)
+	}
 	tag, err := r.git.GetHeadTag()
 	if err != nil {
 		return fmt.Errorf("failed to get git tag: %w", err)
 	}
+	commit, err := r.git.GetCommit()
+	if err != nil {
+		return err
+	}
+	if metadata.Tag != tag || metadata.Commit != commit {
+		return fmt.Errorf("artifacts were built for %s (%s) but HEAD is %s (%s)", metadata.Tag, metadata.Commit, tag, commit)
+	}
 
-	// Check existing release
-	release, err := r.github.GetReleaseByTag(github.GetReleaseByTagParams{
-		Owner: repo.Owner,
-		Repo:  repo.Name,
-		Tag:   tag,
-	})
-	if err == nil && release != nil {
-		fmt.Printf("Release %s already exists\n", tag)
-		return nil
+	// Make sure the artifacts haven't changed since they were built
+	if err := artifacts.Verify(); err != nil {
+		return fmt.Errorf("failed to verify artifacts: %w", err)
 	}
 
-	// Create new release
-	release, err = r.github.CreateRelease(github.CreateReleaseParams{
+	// Load config file
+	data := templateData(metadataBuildInfo(metadata))
+	cfg, err := config.LoadConfig(r.configPath, data)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	switch cfg.Release.OnFailure {
+	case "", "keep", "delete":
+	default:
+		return fmt.Errorf("invalid release.on_failure: %s (expected keep or delete)", cfg.Release.OnFailure)
+	}
+
+	mode := cfg.Release.Mode
+	switch mode {
+	case "":
+		mode = "keep-existing"
+	case "keep-existing", "append", "replace":
+	default:
+		return fmt.Errorf("invalid release.mode: %s (expected keep-existing, append or replace)", mode)
+	}
+
+	// Determine upload parallelism (flag > config > default)
+	uploadParallelism := params.UploadParallelism
+	if uploadParallelism <= 0 {
+		uploadParallelism = cfg.Release.UploadParallelism
+	}
+	if uploadParallelism <= 0 {
+		uploadParallelism = defaultUploadParallelism
+	}
+
+	prerelease, makeLatest, err := releaseStatus(cfg, tag)
+	if err != nil {
+		return err
+	}
+
+	// Get repository info
+	repo, err := r.git.GetRepository()
+	if err != nil {
+		return fmt.Errorf("failed to get repository info: %w", err)
+	}
+
+	// Check existing release
+	release, err := r.backend.GetReleaseByTag(backend.GetReleaseByTagParams{
 		Owner: repo.Owner,
 		Repo:  repo.Name,
 		Tag:   tag,
-		Name:  tag,
-		Draft: params.Draft,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create release: %w", err)
+		return err
 	}
 
-	fmt.Printf("Created release %s\n", tag)
+	// Generate release notes
+	changelog, err := r.releaseNotes(params.ReleaseNotes)
+	if err != nil {
+		return err
+	}
 
-	// Upload assets
-	distFiles, err := filepath.Glob("dist/*")
+	// Render release name and body
+	h, err := resolveHosting(cfg, repo)
 	if err != nil {
-		return fmt.Errorf("failed to list dist files: %w", err)
+		return err
+	}
+	data = releaseTemplateData(data, artifacts, repo, h, changelog)
+	name, body, err := renderRelease(cfg, data)
+	if err != nil {
+		return err
 	}
 
-	for _, file := range distFiles {
-		// Skip .rb files (Homebrew Formula)
-		if filepath.Ext(file) == ".rb" {
-			continue
+	created := false
+	existingAssets := map[string]*backend.Asset{}
+	if release != nil {
+		if params.FailIfExists {
+			return fmt.Errorf("release %s already exists", tag)
 		}
+		fmt.Printf("Release %s already exists, updating it (mode: %s)\n", tag, mode)
 
-		asset := github.Asset{
-			Name: filepath.Base(file),
-			Path: file,
+		// Update release name and notes
+		newName := ""
+		if mode != "keep-existing" && name != release.Name {
+			newName = name
+		}
+		if newBody := releaseBody(mode, release.Body, body); newBody != release.Body || newName != "" {
+			if release, err = r.backend.UpdateRelease(backend.UpdateReleaseParams{
+				Owner:   repo.Owner,
+				Repo:    repo.Name,
+				Release: release,
+				Name:    newName,
+				Body:    newBody,
+			}); err != nil {
+				return err
+			}
 		}
 
-		fmt.Printf("Uploading %s...\n", asset.Name)
-		if err := r.github.UploadAsset(github.UploadAssetParams{
-			Owner:     repo.Owner,
-			Repo:      repo.Name,
-			ReleaseID: release.GetID(),
-			Asset:     asset,
+		// Find assets that are already attached
+		assets, err := r.backend.ListReleaseAssets(backend.ListReleaseAssetsParams{
+			Owner:   repo.Owner,
+			Repo:    repo.Name,
+			Release: release,
+		})
+		if err != nil {
+			return err
+		}
+		for _, asset := range assets {
+			existingAssets[asset.Name] = asset
+		}
+	} else {
+		// Create the release as a draft so that it isn't visible until every asset is uploaded
+		release, err = r.backend.CreateRelease(backend.CreateReleaseParams{
+			Owner:      repo.Owner,
+			Repo:       repo.Name,
+			Tag:        tag,
+			Name:       name,
+			Body:       body,
+			Draft:      true,
+			Prerelease: prerelease,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create release: %w", err)
+		}
+		created = true
+
+		if release.Pending {
+			fmt.Printf("Uploading assets of %s before creating the release, since %s has no draft releases\n", tag, h.Backend)
+		} else {
+			fmt.Printf("Created draft release %s\n", tag)
+		}
+	}
+
+	// Upload and verify assets
+	if err := r.uploadAssets(repo, release, artifacts, existingAssets, mode, uploadParallelism); err != nil {
+		return r.abortRelease(cfg, repo, release, created, err)
+	}
+	if err := r.verifyAssets(repo, release, artifacts, cfg.Release.VerifyDownloads, uploadParallelism); err != nil {
+		return r.abortRelease(cfg, repo, release, created, err)
+	}
+
+	// Publish the release once every asset is in place. Pending releases are created even as drafts.
+	if release.Draft && (!params.Draft || release.Pending) {
+		if _, err := r.backend.PublishRelease(backend.PublishReleaseParams{
+			Owner:      repo.Owner,
+			Repo:       repo.Name,
+			Release:    release,
+			MakeLatest: makeLatest,
+			Draft:      params.Draft,
 		}); err != nil {
-			return fmt.Errorf("failed to upload asset %s: %w", asset.Name, err)
+			return r.abortRelease(cfg, repo, release, created, err)
+		}
+		if params.Draft {
+			fmt.Printf("Warning: created release %s as an upcoming release, which %s lists publicly since it has no draft releases\n", tag, h.Backend)
+		} else {
+			fmt.Printf("Published release %s\n", tag)
 		}
 	}
 
 	// Update Homebrew tap repository if configured
-	cfg, err := config.LoadConfig(r.configPath, nil)
+	if cfg.Brew.Repository.Owner == "" || cfg.Brew.Repository.Name == "" {
+		return nil
+	}
+
+	// Don't let prereleases overwrite stable installs unless requested
+	if prerelease && !cfg.Brew.AllowPrerelease {
+		fmt.Printf("Skipping tap repository update for prerelease %s (set brew.allow_prerelease to update it)\n", tag)
+		return nil
+	}
+
+	tapRepository := fmt.Sprintf("%s/%s", cfg.Brew.Repository.Owner, cfg.Brew.Repository.Name)
+	if err := r.updateTapRepository(tapRepository, artifacts, metadata); err != nil {
+		return fmt.Errorf("failed to update tap repository: %w", err)
+	}
+
+	return nil
+}
+
+// uploadAssets uploads archives and checksums to a release
+func (r *Releaser) uploadAssets(repo *git.Repository, release *backend.Release, artifacts *artifact.Registry, existingAssets map[string]*backend.Asset, mode string, parallelism int) error {
+	// Route all output through the progress so that it doesn't garble the progress on a terminal
+	progress := newUploadProgress(os.Stdout, util.IsTerminal(os.Stdout))
+	r.log.set(progress)
+	defer r.log.set(os.Stderr)
+
+	var uploads []*artifact.Artifact
+	for _, a := range artifacts.List(artifact.ByType(artifact.TypeArchive, artifact.TypeChecksum)) {
+		// Replace assets with the same name unless the existing release is kept as is
+		if existing, ok := existingAssets[a.Name]; ok {
+			if mode == "keep-existing" {
+				_, _ = fmt.Fprintf(progress, "Skipping %s (already uploaded)\n", a.Name)
+				continue
+			}
+			_, _ = fmt.Fprintf(progress, "Deleting existing %s...\n", a.Name)
+			if err := r.backend.DeleteReleaseAsset(backend.DeleteReleaseAssetParams{
+				Owner:   repo.Owner,
+				Repo:    repo.Name,
+				Release: release,
+				Asset:   existing,
+			}); err != nil {
+				return fmt.Errorf("failed to replace asset %s: %w", a.Name, err)
+			}
+		}
+		uploads = append(uploads, a)
+	}
+	if len(uploads) == 0 {
+		return nil
+	}
+
+	// Upload assets concurrently while reporting progress
+	_, _ = fmt.Fprintf(progress, "Uploading %d assets (parallelism: %d)...\n", len(uploads), parallelism)
+	states := make([]*uploadState, len(uploads))
+	for i, a := range uploads {
+		states[i] = progress.add(a.Name, a.Size)
+	}
+	progress.start()
+
+	err := util.Parallel(context.Background(), parallelism, len(uploads), func(ctx context.Context, i int) error {
+		err := r.backend.UploadAsset(ctx, backend.UploadAssetParams{
+			Owner:   repo.Owner,
+			Repo:    repo.Name,
+			Release: release,
+			Name:    uploads[i].Name,
+			Path:    uploads[i].Path,
+			Progress: func(written, total int64) {
+				progress.update(states[i], written, total)
+			},
+		})
+		progress.finish(states[i], err)
+		return err
+	})
+	progress.close()
+
+	return err
+}
+
+// verifyAssets checks that every artifact is attached to the release with the expected size and digest.
+// With download, every asset is downloaded again to compare its SHA-256 with the local file.
+func (r *Releaser) verifyAssets(repo *git.Repository, release *backend.Release, artifacts *artifact.Registry, download bool, parallelism int) error {
+	assets, err := r.backend.ListReleaseAssets(backend.ListReleaseAssetsParams{
+		Owner:   repo.Owner,
+		Repo:    repo.Name,
+		Release: release,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+
+	uploaded := map[string]*backend.Asset{}
+	for _, asset := range assets {
+		uploaded[asset.Name] = asset
 	}
 
-	if cfg.Brew.Repository.Owner != "" && cfg.Brew.Repository.Name != "" {
-		tapRepository := fmt.Sprintf("%s/%s", cfg.Brew.Repository.Owner, cfg.Brew.Repository.Name)
-		if err := r.updateTapRepository(tapRepository); err != nil {
-			return fmt.Errorf("failed to update tap repository: %w", err)
+	// Compare names, sizes and digests
+	expected := artifacts.List(artifact.ByType(artifact.TypeArchive, artifact.TypeChecksum))
+	problems := make([]string, len(expected))
+	for i, a := range expected {
+		asset, ok := uploaded[a.Name]
+		switch {
+		case !ok:
+			problems[i] = "missing"
+		case asset.State != "uploaded":
+			problems[i] = fmt.Sprintf("state is %s, expected uploaded", asset.State)
+		case asset.Size != a.Size:
+			problems[i] = fmt.Sprintf("size is %d, expected %d", asset.Size, a.Size)
+		case asset.Digest != "" && asset.Digest != a.Checksum:
+			problems[i] = fmt.Sprintf("digest is %s, expected %s", asset.Digest, a.Checksum)
 		}
 	}
 
+	// Download assets again to recompute their checksums
+	if download {
+		fmt.Println("Downloading assets to verify their checksums...")
+		if err := util.Parallel(context.Background(), parallelism, len(expected), func(ctx context.Context, i int) error {
+			if problems[i] != "" {
+				return nil
+			}
+			a := expected[i]
+
+			hash := sha256.New()
+			if err := r.backend.DownloadAsset(backend.DownloadAssetParams{
+				Owner:   repo.Owner,
+				Repo:    repo.Name,
+				Release: release,
+				Asset:   uploaded[a.Name],
+			}, hash); err != nil {
+				problems[i] = fmt.Sprintf("download failed: %v", err)
+				return nil
+			}
+			if sum := hex.EncodeToString(hash.Sum(nil)); sum != a.SHA256() {
+				problems[i] = fmt.Sprintf("downloaded SHA-256 is %s, expected %s", sum, a.SHA256())
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	var diff []string
+	for i, a := range expected {
+		if problems[i] != "" {
+			diff = append(diff, fmt.Sprintf("  - %s: %s", a.Name, problems[i]))
+		}
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("%d of %d release assets don't match the artifacts:\n%s", len(diff), len(expected), strings.Join(diff, "\n"))
+	}
+
+	fmt.Printf("Verified %d assets\n", len(expected))
 	return nil
 }
 
-// updateTapRepository updates Homebrew tap repository
-func (r *Releaser) updateTapRepository(repository string) error {
-	fmt.Printf("Updating tap repository %s...\n", repository)
+// abortRelease handles a failure after the release was created or found.
+// A draft created by this run is deleted if release.on_failure is delete, and left for inspection otherwise.
+func (r *Releaser) abortRelease(cfg *config.Config, repo *git.Repository, release *backend.Release, created bool, cause error) error {
+	if !created {
+		return cause
+	}
 
-	// Split tap repository owner and name
-	parts := strings.Split(repository, "/")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid tap repository format: %s", repository)
+	// Pending releases only consist of their uploaded assets
+	what := "the draft release"
+	if release.Pending {
+		what = "the uploaded assets"
+	}
+
+	if cfg.Release.OnFailure != "delete" {
+		fmt.Printf("Leaving %s for inspection (set release.on_failure to delete to remove it)\n", what)
+		return cause
 	}
-	tapOwner, tapRepo := parts[0], parts[1]
 
-	// Get build info
-	buildInfo, err := r.builder.getBuildInfo()
+	fmt.Printf("Deleting %s...\n", what)
+	if err := r.backend.DeleteRelease(backend.DeleteReleaseParams{
+		Owner:   repo.Owner,
+		Repo:    repo.Name,
+		Release: release,
+	}); err != nil {
+		return fmt.Errorf("%w (and failed to delete %s: %v)", cause, what, err)
+	}
+	return cause
+}
+
+// releaseNotes returns the release notes, read from path if given and generated from the git history otherwise
+func (r *Releaser) releaseNotes(path string) (string, error) {
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read release notes: %w", err)
+		}
+		return string(content), nil
+	}
+
+	body, err := r.changelog.Generate()
 	if err != nil {
-		return fmt.Errorf("failed to get build info: %w", err)
+		return "", fmt.Errorf("failed to generate changelog: %w", err)
+	}
+	return body, nil
+}
+
+// releaseStatus determines whether the release is a prerelease and the make_latest value for GitHub
+func releaseStatus(cfg *config.Config, tag string) (bool, string, error) {
+	var prerelease bool
+	switch cfg.Release.Prerelease {
+	case "", "auto":
+		// Tags that aren't semantic versions are treated as stable releases
+		if v, err := semver.Parse(tag); err == nil {
+			prerelease = v.IsPrerelease()
+		}
+	case "true":
+		prerelease = true
+	case "false":
+	default:
+		return false, "", fmt.Errorf("invalid release.prerelease: %s (expected auto, true or false)", cfg.Release.Prerelease)
+	}
+
+	makeLatest := cfg.Release.MakeLatest
+	switch makeLatest {
+	case "", "auto":
+		makeLatest = "true"
+		if prerelease {
+			makeLatest = "false"
+		}
+	case "true", "false", "legacy":
+	default:
+		return false, "", fmt.Errorf("invalid release.make_latest: %s (expected auto, true, false or legacy)", cfg.Release.MakeLatest)
 	}
 
+	return prerelease, makeLatest, nil
+}
+
+// renderRelease renders the release name and body. The body consists of the header, the changelog and the footer.
+func renderRelease(cfg *config.Config, data map[string]any) (string, string, error) {
+	nameTemplate := cfg.Release.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = "{{ .Tag }}"
+	}
+	name, err := nameTemplate.Execute(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render release name: %w", err)
+	}
+
+	header, err := cfg.Release.Header.Execute(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render release header: %w", err)
+	}
+	footer, err := cfg.Release.Footer.Execute(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render release footer: %w", err)
+	}
+
+	var parts []string
+	for _, part := range []string{header, data["Changelog"].(string), footer} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	body := strings.Join(parts, "\n\n")
+	if body != "" {
+		body += "\n"
+	}
+
+	return name, body, nil
+}
+
+// releaseBody returns the notes of an existing release updated according to the release mode.
+// Notes that were already appended, e.g. by an earlier run that failed, aren't appended again.
+func releaseBody(mode, existing, body string) string {
+	switch {
+	case body == "" || mode == "keep-existing":
+		return existing
+	case mode == "append" && strings.Contains(existing, strings.TrimSpace(body)):
+		return existing
+	case mode == "append" && existing != "":
+		return existing + "\n\n" + body
+	default:
+		return body
+	}
+}
+
+// updateTapRepository updates Homebrew tap repository
+func (r *Releaser) updateTapRepository(repository string, artifacts *artifact.Registry, metadata *artifact.Metadata) error {
+	fmt.Printf("Updating tap repository %s...\n", repository)
+
+	// Split tap repository owner (or GitLab group path) and name
+	i := strings.LastIndex(repository, "/")
+	if i <= 0 || i == len(repository)-1 {
+		return fmt.Errorf("invalid tap repository format: %s", repository)
+	}
+	tapOwner, tapRepo := repository[:i], repository[i+1:]
+
 	// Read Formula file
-	moduleName := filepath.Base(buildInfo.Module)
-	formulaPath := filepath.Join("dist", fmt.Sprintf("%s.rb", moduleName))
-	content, err := os.ReadFile(formulaPath)
+	formulas := artifacts.List(artifact.ByType(artifact.TypeFormula))
+	if len(formulas) == 0 {
+		return fmt.Errorf("no formula was generated")
+	}
+	content, err := os.ReadFile(formulas[0].Path)
 	if err != nil {
 		return fmt.Errorf("failed to read formula file: %w", err)
 	}
 
 	// Update tap repository
-	tapFormulaPath := fmt.Sprintf("Formula/%s.rb", moduleName)
-	tapCommitMessage := fmt.Sprintf("Update %s to %s", moduleName, buildInfo.Version)
+	tapFormulaPath := fmt.Sprintf("Formula/%s.rb", metadata.ProjectName)
+	tapCommitMessage := fmt.Sprintf("Update %s to %s", metadata.ProjectName, metadata.Tag)
 
-	if err := r.github.UpdateFile(github.UpdateFileParams{
+	if err := r.backend.UpdateFile(backend.UpdateFileParams{
 		Owner:         tapOwner,
 		Repo:          tapRepo,
 		Path:          tapFormulaPath,
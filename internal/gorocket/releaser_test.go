@@ -0,0 +1,604 @@
+package gorocket
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/koki-develop/gorocket/internal/backend"
+	"github.com/koki-develop/gorocket/internal/backend/backendtest"
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/koki-develop/gorocket/internal/gitlab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initTestRepository creates a git repository in a temporary directory and changes into it
+func initTestRepository(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "gorocket")
+	t.Setenv("GIT_AUTHOR_EMAIL", "gorocket@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "gorocket")
+	t.Setenv("GIT_COMMITTER_EMAIL", "gorocket@example.com")
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	runGit(t, "init", "--quiet")
+}
+
+// commitTestRepository creates an empty commit, tags it unless tag is empty and returns its hash
+func commitTestRepository(t *testing.T, tag string) string {
+	runGit(t, "commit", "--quiet", "--allow-empty", "--message", "commit")
+	if tag != "" {
+		runGit(t, "tag", tag)
+	}
+	return runGit(t, "rev-parse", "HEAD")
+}
+
+// runGit runs git and returns its trimmed output
+func runGit(t *testing.T, args ...string) string {
+	output, err := exec.Command("git", args...).Output()
+	require.NoError(t, err, "git %s", strings.Join(args, " "))
+	return strings.TrimSpace(string(output))
+}
+
+func Test_Publish_refusals(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, metadata *artifact.Metadata, archivePath string)
+		wantErr string // Regular expression
+	}{
+		{
+			name: "snapshot",
+			setup: func(t *testing.T, metadata *artifact.Metadata, archivePath string) {
+				metadata.Tag = "v1.0.0-SNAPSHOT-abc1234"
+				metadata.IsSnapshot = true
+			},
+			wantErr: `^cannot publish a snapshot build \(v1\.0\.0-SNAPSHOT-abc1234\)$`,
+		},
+		{
+			name: "HEAD isn't tagged",
+			setup: func(t *testing.T, metadata *artifact.Metadata, archivePath string) {
+				commitTestRepository(t, "")
+			},
+			wantErr: `^failed to get git tag: `,
+		},
+		{
+			name: "HEAD isn't the tagged commit",
+			setup: func(t *testing.T, metadata *artifact.Metadata, archivePath string) {
+				commitTestRepository(t, "v1.0.1")
+			},
+			wantErr: `^artifacts were built for v1\.0\.0 \([0-9a-f]{40}\) but HEAD is v1\.0\.1 \([0-9a-f]{40}\)$`,
+		},
+		{
+			name: "artifacts changed since the build",
+			setup: func(t *testing.T, metadata *artifact.Metadata, archivePath string) {
+				require.NoError(t, os.WriteFile(archivePath, []byte("changed"), 0644))
+			},
+			wantErr: `^failed to verify artifacts: `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestRepository(t)
+			commit := commitTestRepository(t, "v1.0.0")
+
+			dist := t.TempDir()
+			archivePath := filepath.Join(dist, "app_linux_amd64.tar.gz")
+			require.NoError(t, os.WriteFile(archivePath, []byte("archive"), 0644))
+			artifacts := artifact.New()
+			require.NoError(t, artifacts.Add(&artifact.Artifact{Type: artifact.TypeArchive, Path: archivePath, OS: "linux", Arch: "amd64"}))
+			metadata := &artifact.Metadata{ProjectName: "app", Tag: "v1.0.0", Commit: commit}
+
+			tt.setup(t, metadata, archivePath)
+			require.NoError(t, artifacts.Write(dist, metadata))
+
+			// The refusals come before the backend is used
+			r := &Releaser{git: git.New(), builder: &Builder{distDir: dist}}
+			err := r.Publish(PublishParams{})
+			require.Error(t, err)
+			assert.Regexp(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func Test_releaseBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		existing string
+		body     string
+		expected string
+	}{
+		{
+			name:     "keep-existing",
+			mode:     "keep-existing",
+			existing: "Hand-written notes",
+			body:     "## Changelog\n",
+			expected: "Hand-written notes",
+		},
+		{
+			name:     "keep-existing without notes",
+			mode:     "keep-existing",
+			body:     "## Changelog\n",
+			expected: "",
+		},
+		{
+			name:     "append",
+			mode:     "append",
+			existing: "Hand-written notes",
+			body:     "## Changelog\n",
+			expected: "Hand-written notes\n\n## Changelog\n",
+		},
+		{
+			name:     "append to empty notes",
+			mode:     "append",
+			body:     "## Changelog\n",
+			expected: "## Changelog\n",
+		},
+		{
+			name:     "append again",
+			mode:     "append",
+			existing: "Hand-written notes\n\n## Changelog\n",
+			body:     "## Changelog\n",
+			expected: "Hand-written notes\n\n## Changelog\n",
+		},
+		{
+			name:     "append again after the notes were trimmed",
+			mode:     "append",
+			existing: "Hand-written notes\r\n\r\n## Changelog",
+			body:     "## Changelog\n",
+			expected: "Hand-written notes\r\n\r\n## Changelog",
+		},
+		{
+			name:     "append nothing",
+			mode:     "append",
+			existing: "Hand-written notes",
+			expected: "Hand-written notes",
+		},
+		{
+			name:     "replace",
+			mode:     "replace",
+			existing: "Hand-written notes",
+			body:     "## Changelog\n",
+			expected: "## Changelog\n",
+		},
+		{
+			name:     "replace with nothing",
+			mode:     "replace",
+			existing: "Hand-written notes",
+			expected: "Hand-written notes",
+		},
+		{
+			name:     "replace empty notes",
+			mode:     "replace",
+			body:     "## Changelog\n",
+			expected: "## Changelog\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, releaseBody(tt.mode, tt.existing, tt.body))
+		})
+	}
+}
+
+func Test_renderRelease(t *testing.T) {
+	data := templateData(&BuildInfo{Module: "github.com/owner/app", Version: "v1.2.0", PreviousTag: "v1.1.0"})
+	data["Changelog"] = "## Changelog\n\n- feat: add things\n"
+
+	tests := []struct {
+		name         string
+		configure    func(cfg *config.Config)
+		expectedName string
+		expectedBody string
+		wantErr      string
+	}{
+		{
+			name:         "defaults",
+			expectedName: "v1.2.0",
+			expectedBody: "## Changelog\n\n- feat: add things\n",
+		},
+		{
+			name: "templates",
+			configure: func(cfg *config.Config) {
+				cfg.Release.NameTemplate = "{{ .ProjectName }} {{ trimprefix .Tag \"v\" }}"
+				cfg.Release.Header = "Version {{ .Major }}.{{ .Minor }} of {{ .ProjectName }}\n"
+				cfg.Release.Footer = "**Full Changelog**: {{ .PreviousTag }}...{{ .Tag }}"
+			},
+			expectedName: "app 1.2.0",
+			expectedBody: "Version 1.2 of app\n\n## Changelog\n\n- feat: add things\n\n**Full Changelog**: v1.1.0...v1.2.0\n",
+		},
+		{
+			name: "empty parts are left out",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Header = "{{ if .IsSnapshot }}Snapshot{{ end }}"
+				cfg.Release.Footer = "Thanks!"
+			},
+			expectedName: "v1.2.0",
+			expectedBody: "## Changelog\n\n- feat: add things\n\nThanks!\n",
+		},
+		{
+			name: "broken name template",
+			configure: func(cfg *config.Config) {
+				cfg.Release.NameTemplate = "{{ .Tag"
+			},
+			wantErr: "failed to render release name: ",
+		},
+		{
+			name: "broken header template",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Header = "{{ unknown }}"
+			},
+			wantErr: "failed to render release header: ",
+		},
+		{
+			name: "broken footer template",
+			configure: func(cfg *config.Config) {
+				cfg.Release.Footer = "{{ .Tag | lower 1 }}"
+			},
+			wantErr: "failed to render release footer: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+
+			name, body, err := renderRelease(&cfg, data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedName, name)
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func Test_releaseStatus(t *testing.T) {
+	tests := []struct {
+		name               string
+		tag                string
+		prerelease         string
+		makeLatest         string
+		expectedPrerelease bool
+		expectedMakeLatest string
+		wantErr            string
+	}{
+		{
+			name:               "stable",
+			tag:                "v1.2.0",
+			expectedMakeLatest: "true",
+		},
+		{
+			name:               "release candidate",
+			tag:                "v1.2.0-rc.1",
+			expectedPrerelease: true,
+			expectedMakeLatest: "false",
+		},
+		{
+			name:               "build metadata isn't a prerelease",
+			tag:                "v1.2.0+build.5",
+			expectedMakeLatest: "true",
+		},
+		{
+			name:               "invalid semver tags are stable releases",
+			tag:                "nightly",
+			expectedMakeLatest: "true",
+		},
+		{
+			name:               "auto",
+			tag:                "v1.2.0-beta.2",
+			prerelease:         "auto",
+			makeLatest:         "auto",
+			expectedPrerelease: true,
+			expectedMakeLatest: "false",
+		},
+		{
+			name:               "forced prerelease",
+			tag:                "v1.2.0",
+			prerelease:         "true",
+			expectedPrerelease: true,
+			expectedMakeLatest: "false",
+		},
+		{
+			name:               "forced stable release",
+			tag:                "v1.2.0-rc.1",
+			prerelease:         "false",
+			expectedMakeLatest: "true",
+		},
+		{
+			name:               "explicit make_latest",
+			tag:                "v1.2.0-rc.1",
+			makeLatest:         "legacy",
+			expectedPrerelease: true,
+			expectedMakeLatest: "legacy",
+		},
+		{
+			name:               "stable release that isn't the latest",
+			tag:                "v1.1.5",
+			makeLatest:         "false",
+			expectedMakeLatest: "false",
+		},
+		{
+			name:       "invalid prerelease",
+			tag:        "v1.2.0",
+			prerelease: "maybe",
+			wantErr:    "invalid release.prerelease: maybe (expected auto, true or false)",
+		},
+		{
+			name:       "invalid make_latest",
+			tag:        "v1.2.0",
+			makeLatest: "yes",
+			wantErr:    "invalid release.make_latest: yes (expected auto, true, false or legacy)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			cfg.Release.Prerelease = tt.prerelease
+			cfg.Release.MakeLatest = tt.makeLatest
+
+			prerelease, makeLatest, err := releaseStatus(&cfg, tt.tag)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPrerelease, prerelease)
+			assert.Equal(t, tt.expectedMakeLatest, makeLatest)
+		})
+	}
+}
+
+// fakePackage is a generic GitLab package holding the assets of a pending release, served with backendtest
+type fakePackage struct {
+	*backendtest.Server
+	files   map[string]*fakeAsset
+	deleted bool
+}
+
+// fakeAsset is a package file. Its listed size and digest can differ from its content.
+type fakeAsset struct {
+	content []byte
+	size    int64
+	sha256  string
+}
+
+func newFakePackage(t *testing.T, contents map[string]string) (*fakePackage, backend.Backend) {
+	t.Helper()
+
+	f := &fakePackage{files: map[string]*fakeAsset{}}
+	for name, content := range contents {
+		sum := sha256.Sum256([]byte(content))
+		f.files[name] = &fakeAsset{content: []byte(content), size: int64(len(content)), sha256: hex.EncodeToString(sum[:])}
+	}
+	f.Server = backendtest.NewServer(t, f.handle)
+
+	c, err := gitlab.New("token", gitlab.Options{APIURL: f.URL + "/api/v4"})
+	require.NoError(t, err)
+	return f, c
+}
+
+func (f *fakePackage) handle(w http.ResponseWriter, r *http.Request) {
+	const project = "/api/v4/projects/owner%2Fapp"
+
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodGet && path == project+"/packages":
+		packages := []map[string]any{}
+		if !f.deleted {
+			packages = append(packages, map[string]any{"id": 1, "name": "app", "version": "v1.0.0"})
+		}
+		f.Reply(w, http.StatusOK, packages)
+
+	case r.Method == http.MethodGet && path == project+"/packages/1/package_files":
+		list := []map[string]any{}
+		for i, name := range slices.Sorted(maps.Keys(f.files)) {
+			file := f.files[name]
+			list = append(list, map[string]any{"id": i + 1, "file_name": name, "size": file.size, "file_sha256": file.sha256})
+		}
+		f.Reply(w, http.StatusOK, list)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, project+"/packages/generic/app/v1.0.0/"):
+		file, ok := f.files[strings.TrimPrefix(path, project+"/packages/generic/app/v1.0.0/")]
+		if !ok {
+			f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not Found"})
+			return
+		}
+		_, _ = w.Write(file.content)
+
+	case r.Method == http.MethodDelete && path == project+"/releases/v1.0.0":
+		// Pending releases don't exist yet
+		f.Reply(w, http.StatusNotFound, map[string]any{"message": "404 Not Found"})
+
+	case r.Method == http.MethodDelete && path == project+"/packages/1":
+		f.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.Unexpected(w, r)
+	}
+}
+
+// testArtifacts writes archives and a checksums file with the given contents and registers them
+func testArtifacts(t *testing.T, contents map[string]string) *artifact.Registry {
+	t.Helper()
+
+	dir := t.TempDir()
+	artifacts := artifact.New()
+	for _, name := range slices.Sorted(maps.Keys(contents)) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(contents[name]), 0644))
+
+		typ := artifact.TypeArchive
+		if strings.HasSuffix(name, ".txt") {
+			typ = artifact.TypeChecksum
+		}
+		require.NoError(t, artifacts.Add(&artifact.Artifact{Type: typ, Path: path}))
+	}
+	return artifacts
+}
+
+func Test_verifyAssets(t *testing.T) {
+	contents := map[string]string{
+		"app_darwin_arm64.tar.gz": "darwin",
+		"app_linux_amd64.tar.gz":  "linux",
+		"checksums.txt":           "sums",
+	}
+	sha256Of := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name     string
+		change   func(files map[string]*fakeAsset)
+		download bool
+		wantErr  string
+	}{
+		{
+			name: "every asset matches",
+		},
+		{
+			name:     "every downloaded asset matches",
+			download: true,
+		},
+		{
+			name: "missing asset",
+			change: func(files map[string]*fakeAsset) {
+				delete(files, "checksums.txt")
+			},
+			wantErr: "1 of 3 release assets don't match the artifacts:\n  - checksums.txt: missing",
+		},
+		{
+			name: "size mismatch",
+			change: func(files map[string]*fakeAsset) {
+				files["app_linux_amd64.tar.gz"].size = 3
+			},
+			wantErr: "1 of 3 release assets don't match the artifacts:\n  - app_linux_amd64.tar.gz: size is 3, expected 5",
+		},
+		{
+			name: "digest mismatch",
+			change: func(files map[string]*fakeAsset) {
+				files["app_darwin_arm64.tar.gz"].sha256 = sha256Of("other")
+			},
+			wantErr: "1 of 3 release assets don't match the artifacts:\n  - app_darwin_arm64.tar.gz: digest is sha256:" + sha256Of("other") + ", expected sha256:" + sha256Of("darwin"),
+		},
+		{
+			name: "downloaded content mismatch",
+			change: func(files map[string]*fakeAsset) {
+				files["app_linux_amd64.tar.gz"].content = []byte("LINUX")
+			},
+			download: true,
+			wantErr:  "1 of 3 release assets don't match the artifacts:\n  - app_linux_amd64.tar.gz: downloaded SHA-256 is " + sha256Of("LINUX") + ", expected " + sha256Of("linux"),
+		},
+		{
+			name: "every problem is listed",
+			change: func(files map[string]*fakeAsset) {
+				delete(files, "checksums.txt")
+				files["app_linux_amd64.tar.gz"].size = 3
+			},
+			wantErr: "2 of 3 release assets don't match the artifacts:\n  - app_linux_amd64.tar.gz: size is 3, expected 5\n  - checksums.txt: missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, b := newFakePackage(t, contents)
+			if tt.change != nil {
+				tt.change(f.files)
+			}
+
+			r := &Releaser{backend: b}
+			release := &backend.Release{Tag: "v1.0.0", Draft: true, Pending: true}
+			err := r.verifyAssets(&git.Repository{Owner: "owner", Name: "app"}, release, testArtifacts(t, contents), tt.download, 2)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_abortRelease(t *testing.T) {
+	cause := errors.New("upload failed")
+
+	tests := []struct {
+		name          string
+		onFailure     string
+		created       bool
+		expectDeleted bool
+	}{
+		{
+			name:      "existing releases are kept",
+			onFailure: "delete",
+		},
+		{
+			name:    "created releases are kept by default",
+			created: true,
+		},
+		{
+			name:      "created releases are kept",
+			onFailure: "keep",
+			created:   true,
+		},
+		{
+			name:          "created releases are deleted",
+			onFailure:     "delete",
+			created:       true,
+			expectDeleted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, b := newFakePackage(t, map[string]string{"app_linux_amd64.tar.gz": "linux"})
+
+			var cfg config.Config
+			cfg.Release.OnFailure = tt.onFailure
+			r := &Releaser{backend: b}
+			release := &backend.Release{Tag: "v1.0.0", Draft: true, Pending: true}
+			err := r.abortRelease(&cfg, &git.Repository{Owner: "owner", Name: "app"}, release, tt.created, cause)
+			assert.Same(t, cause, err)
+			assert.Equal(t, tt.expectDeleted, f.deleted)
+		})
+	}
+
+	// Failures to delete are reported along with the cause
+	t.Run("delete fails", func(t *testing.T) {
+		f, b := newFakePackage(t, nil)
+		f.Close()
+
+		var cfg config.Config
+		cfg.Release.OnFailure = "delete"
+		r := &Releaser{backend: b}
+		release := &backend.Release{Tag: "v1.0.0", Draft: true, Pending: true}
+		err := r.abortRelease(&cfg, &git.Repository{Owner: "owner", Name: "app"}, release, true, cause)
+		assert.ErrorIs(t, err, cause)
+		assert.ErrorContains(t, err, "upload failed (and failed to delete the uploaded assets: ")
+	})
+}
@@ -0,0 +1,108 @@
+package gorocket
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/koki-develop/gorocket/internal/util"
+)
+
+// verifyReproducible builds twice into separate directories and compares the checksums of the artifacts
+func (b *Builder) verifyReproducible(params BuildParams) error {
+	params.VerifyReproducible = false
+
+	// First build into the dist directory
+	if err := b.Build(params); err != nil {
+		return err
+	}
+
+	// Second build into a temporary directory with an empty build cache,
+	// so that nothing is reused from the first build
+	verifyDir, err := os.MkdirTemp("", "gorocket-verify-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(verifyDir) }()
+
+	cacheDir, err := os.MkdirTemp("", "gorocket-gocache-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+
+	fmt.Println("Rebuilding to verify reproducibility...")
+	second := *b
+	second.distDir = filepath.Join(verifyDir, "dist")
+	second.env = append(slices.Clone(b.env), "GOCACHE="+cacheDir)
+	if err := second.Build(params); err != nil {
+		return fmt.Errorf("failed to rebuild: %w", err)
+	}
+
+	// Compare checksums
+	first, err := checksumDir(b.distDir)
+	if err != nil {
+		return err
+	}
+	rebuilt, err := checksumDir(second.distDir)
+	if err != nil {
+		return err
+	}
+
+	var diffs []string
+	for name, sum := range first {
+		other, ok := rebuilt[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("  - %s: missing in second build", name))
+		case sum != other:
+			diffs = append(diffs, fmt.Sprintf("  - %s: %s != %s", name, sum, other))
+		}
+	}
+	for name := range rebuilt {
+		if _, ok := first[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("  - %s: missing in first build", name))
+		}
+	}
+
+	if len(diffs) > 0 {
+		slices.Sort(diffs)
+		return fmt.Errorf("build is not reproducible:\n%s", strings.Join(diffs, "\n"))
+	}
+
+	fmt.Printf("Verified %d artifacts are reproducible\n", len(first))
+	return nil
+}
+
+// checksumDir calculates the SHA256 of every file in a directory
+func checksumDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	sums := map[string]string{}
+	for _, entry := range entries {
+		// The manifest records paths and dates rather than build results
+		if entry.IsDir() || entry.Name() == artifact.ArtifactsFile || entry.Name() == artifact.MetadataFile {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		sum, err := util.CalculateSHA256(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate SHA256 for %s: %w", path, err)
+		}
+		sums[entry.Name()] = sum
+	}
+
+	return sums, nil
+}
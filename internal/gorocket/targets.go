@@ -0,0 +1,190 @@
+package gorocket
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/koki-develop/gorocket/internal/golang"
+)
+
+// variantPatterns defines valid values of the micro-architecture environment variables
+var variantPatterns = map[string]*regexp.Regexp{
+	"GOARM":    regexp.MustCompile(`^[5-7](,(softfloat|hardfloat))?$`),
+	"GOAMD64":  regexp.MustCompile(`^v[1-4]$`),
+	"GOARM64":  regexp.MustCompile(`^v(8\.[0-9]|9\.[0-5])(,(lse|crypto))*$`),
+	"GO386":    regexp.MustCompile(`^(sse2|softfloat)$`),
+	"GOMIPS":   regexp.MustCompile(`^(hardfloat|softfloat)$`),
+	"GOMIPS64": regexp.MustCompile(`^(hardfloat|softfloat)$`),
+}
+
+// validateJobs checks every job of the build matrix against the platforms supported by the toolchain
+// and reports all problems at once
+func (b *Builder) validateJobs(jobs []*buildJob) error {
+	platforms, err := b.golang.ListPlatforms()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, job := range jobs {
+		_, jobProblems := checkJob(job, platforms)
+		for _, problem := range jobProblems {
+			problems = append(problems, fmt.Sprintf("  - %s: %s", platformName(job.OS, job.Arch, job.Variant), problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid targets:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// checkJob returns the platform of a job and any problems that prevent it from being built
+func checkJob(job *buildJob, platforms []golang.Platform) (*golang.Platform, []string) {
+	i := slices.IndexFunc(platforms, func(p golang.Platform) bool {
+		return p.OS == job.OS && p.Arch == job.Arch
+	})
+	if i < 0 {
+		problem := "unsupported by the Go toolchain"
+		if suggestions := suggestPlatforms(job.OS+"/"+job.Arch, platforms); len(suggestions) > 0 {
+			problem += fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, " or "))
+		}
+		return nil, []string{problem}
+	}
+	platform := &platforms[i]
+
+	var problems []string
+
+	// Check micro-architecture variant
+	if job.Variant != "" {
+		name := variantEnvName(job.Arch)
+		if !variantPatterns[name].MatchString(job.Variant) {
+			problems = append(problems, fmt.Sprintf("invalid %s value %q", name, job.Variant))
+		}
+	}
+
+	// Check cgo support
+	if !platform.CgoSupported {
+		for _, build := range job.Builds {
+			if slices.Contains(build.Env, "CGO_ENABLED=1") {
+				problems = append(problems, fmt.Sprintf("cgo is not supported but build %s sets CGO_ENABLED=1", build.ID))
+			}
+		}
+	}
+
+	return platform, problems
+}
+
+// suggestPlatforms returns supported platforms similar to the given OS/arch pair
+func suggestPlatforms(name string, platforms []golang.Platform) []string {
+	const maxDistance = 3
+
+	best := maxDistance + 1
+	var suggestions []string
+	for _, p := range platforms {
+		candidate := p.OS + "/" + p.Arch
+		d := levenshtein(name, candidate)
+		switch {
+		case d < best:
+			best = d
+			suggestions = []string{candidate}
+		case d == best:
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// ListTargets prints the resolved build matrix
+func (b *Builder) ListTargets(w io.Writer) error {
+	// Targets can be listed without a git tag
+	b.allowDirty = true
+
+	buildInfo, err := b.getBuildInfo()
+	if err != nil {
+		return err
+	}
+
+	_, jobs, err := b.plan(buildInfo)
+	if err != nil {
+		return err
+	}
+
+	platforms, err := b.golang.ListPlatforms()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TARGET\tBUILDS\tCGO\tFIRST CLASS\tSTATUS")
+
+	invalid := 0
+	for _, job := range jobs {
+		var ids []string
+		for _, build := range job.Builds {
+			ids = append(ids, build.ID)
+		}
+
+		platform, problems := checkJob(job, platforms)
+		cgo, firstClass := "-", "-"
+		if platform != nil {
+			cgo, firstClass = yesNo(platform.CgoSupported), yesNo(platform.FirstClass)
+		}
+		status := "ok"
+		if len(problems) > 0 {
+			status = strings.Join(problems, "; ")
+			invalid++
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			platformName(job.OS, job.Arch, job.Variant), strings.Join(ids, ","), cgo, firstClass, status)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write targets: %w", err)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid target(s)", invalid)
+	}
+	return nil
+}
+
+// yesNo formats a boolean for display
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
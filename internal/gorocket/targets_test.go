@@ -0,0 +1,117 @@
+package gorocket
+
+import (
+	"testing"
+
+	"github.com/koki-develop/gorocket/internal/config"
+	"github.com/koki-develop/gorocket/internal/golang"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPlatforms = []golang.Platform{
+	{OS: "darwin", Arch: "amd64", CgoSupported: true, FirstClass: true},
+	{OS: "darwin", Arch: "arm64", CgoSupported: true, FirstClass: true},
+	{OS: "linux", Arch: "amd64", CgoSupported: true, FirstClass: true},
+	{OS: "linux", Arch: "arm", CgoSupported: true, FirstClass: true},
+	{OS: "linux", Arch: "arm64", CgoSupported: true, FirstClass: true},
+	{OS: "js", Arch: "wasm"},
+	{OS: "windows", Arch: "amd64", CgoSupported: true, FirstClass: true},
+}
+
+func Test_checkJob(t *testing.T) {
+	cgoBuild := config.Build{ID: "app", BuildOptions: config.BuildOptions{Env: []config.Template{"CGO_ENABLED=1"}}}
+
+	tests := []struct {
+		name     string
+		job      *buildJob
+		expected []string
+	}{
+		{
+			name: "supported platform",
+			job:  &buildJob{OS: "linux", Arch: "amd64"},
+		},
+		{
+			name: "valid variant",
+			job:  &buildJob{OS: "linux", Arch: "arm", Variant: "7"},
+		},
+		{
+			name:     "invalid variant",
+			job:      &buildJob{OS: "linux", Arch: "amd64", Variant: "v5"},
+			expected: []string{`invalid GOAMD64 value "v5"`},
+		},
+		{
+			name:     "typo in OS",
+			job:      &buildJob{OS: "linx", Arch: "amd64"},
+			expected: []string{"unsupported by the Go toolchain (did you mean linux/amd64?)"},
+		},
+		{
+			name:     "unknown platform without suggestions",
+			job:      &buildJob{OS: "plan9", Arch: "riscv64"},
+			expected: []string{"unsupported by the Go toolchain"},
+		},
+		{
+			name:     "cgo on a platform without cgo",
+			job:      &buildJob{OS: "js", Arch: "wasm", Builds: []config.Build{cgoBuild}},
+			expected: []string{"cgo is not supported but build app sets CGO_ENABLED=1"},
+		},
+		{
+			name: "cgo on a platform with cgo",
+			job:  &buildJob{OS: "linux", Arch: "amd64", Builds: []config.Build{cgoBuild}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems := checkJob(tt.job, testPlatforms)
+			assert.Equal(t, tt.expected, problems)
+		})
+	}
+}
+
+func Test_suggestPlatforms(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "typo",
+			input:    "darwn/arm64",
+			expected: []string{"darwin/arm64"},
+		},
+		{
+			name:     "several candidates at the same distance",
+			input:    "linux/arm32",
+			expected: []string{"linux/arm", "linux/arm64"},
+		},
+		{
+			name:  "too different",
+			input: "freebsd/riscv64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, suggestPlatforms(tt.input, testPlatforms))
+		})
+	}
+}
+
+func Test_validateJobs(t *testing.T) {
+	b := &Builder{golang: golang.New()}
+
+	assert.NoError(t, b.validateJobs([]*buildJob{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "darwin", Arch: "arm64"},
+	}))
+
+	// Every problem is reported at once
+	err := b.validateJobs([]*buildJob{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "linux", Arch: "arm", Variant: "8"},
+		{OS: "windws", Arch: "amd64"},
+	})
+	assert.EqualError(t, err, "invalid targets:\n"+
+		"  - linux/armv8: invalid GOARM value \"8\"\n"+
+		"  - windws/amd64: unsupported by the Go toolchain (did you mean windows/amd64?)")
+}
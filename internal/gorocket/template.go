@@ -0,0 +1,142 @@
+package gorocket
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koki-develop/gorocket/internal/artifact"
+	"github.com/koki-develop/gorocket/internal/git"
+	"github.com/koki-develop/gorocket/internal/semver"
+)
+
+// templateData returns the data available in config templates
+func templateData(buildInfo *BuildInfo) map[string]any {
+	data := map[string]any{
+		"ProjectName": filepath.Base(buildInfo.Module),
+		"Module":      buildInfo.Module,
+		"Version":     buildInfo.Version,
+		"Tag":         buildInfo.Version,
+		"PreviousTag": buildInfo.PreviousTag,
+		"Commit":      buildInfo.Commit,
+		"ShortCommit": buildInfo.ShortCommit,
+		"CommitDate":  formatDate(buildInfo.CommitDate),
+		"Date":        formatDate(buildInfo.Date),
+		"IsSnapshot":  buildInfo.IsSnapshot,
+		"Major":       0,
+		"Minor":       0,
+		"Patch":       0,
+		"Prerelease":  "",
+		"Env":         environ(),
+		"Os":          "",
+		"Arch":        "",
+		"Variant":     "",
+		"ArchName":    "",
+		"Binary":      "",
+	}
+
+	// Semantic version parts are left empty for non-semver tags
+	if v, err := semver.Parse(buildInfo.Version); err == nil {
+		data["Major"] = v.Major
+		data["Minor"] = v.Minor
+		data["Patch"] = v.Patch
+		data["Prerelease"] = v.Prerelease
+	}
+
+	return data
+}
+
+// targetTemplateData returns a copy of data with the target set
+func targetTemplateData(data map[string]any, goos, goarch, variant string) map[string]any {
+	data = maps.Clone(data)
+	data["Os"] = goos
+	data["Arch"] = goarch
+	data["Variant"] = variant
+	data["ArchName"] = archName(goarch, variant)
+	return data
+}
+
+// environ returns the environment variables as a map
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// formatDate formats a date for templates, leaving zero dates empty
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// metadataBuildInfo restores build information from the artifact manifest
+func metadataBuildInfo(metadata *artifact.Metadata) *BuildInfo {
+	return &BuildInfo{
+		Module:      metadata.Module,
+		Version:     metadata.Tag,
+		PreviousTag: metadata.PreviousTag,
+		Commit:      metadata.Commit,
+		ShortCommit: metadata.ShortCommit,
+		CommitDate:  metadata.CommitDate,
+		Date:        metadata.Date,
+		IsSnapshot:  metadata.IsSnapshot,
+	}
+}
+
+// releaseTemplateData returns a copy of data with the release notes and the uploaded artifacts
+func releaseTemplateData(data map[string]any, artifacts *artifact.Registry, repo *git.Repository, h hosting, changelog string) map[string]any {
+	data = maps.Clone(data)
+	tag, _ := data["Tag"].(string)
+
+	var list []map[string]any
+	var table, checksums strings.Builder
+	table.WriteString("| File | OS | Arch | Size | SHA256 |\n")
+	table.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, a := range artifacts.List(artifact.ByType(artifact.TypeArchive, artifact.TypeChecksum)) {
+		url := downloadURL(h, repo, tag, a.Name)
+		list = append(list, map[string]any{
+			"Name":    a.Name,
+			"URL":     url,
+			"Type":    string(a.Type),
+			"Os":      a.OS,
+			"Arch":    a.Arch,
+			"Variant": a.Variant,
+			"Size":    a.Size,
+			"SHA256":  a.SHA256(),
+		})
+		fmt.Fprintf(&table, "| [%s](%s) | %s | %s | %s | `%s` |\n", a.Name, url, a.OS, archName(a.Arch, a.Variant), formatSize(a.Size), a.SHA256())
+
+		if a.Type == artifact.TypeArchive {
+			fmt.Fprintf(&checksums, "%s  %s\n", a.SHA256(), a.Name)
+		}
+	}
+
+	data["Changelog"] = changelog
+	data["Artifacts"] = list
+	data["ArtifactTable"] = table.String()
+	data["Checksums"] = "```\n" + checksums.String() + "```\n"
+	return data
+}
+
+// formatSize formats a file size in binary units
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
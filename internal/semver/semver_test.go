@@ -0,0 +1,64 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Version
+		wantErr  bool
+	}{
+		{
+			name:     "with v prefix",
+			input:    "v1.2.3",
+			expected: &Version{Major: 1, Minor: 2, Patch: 3},
+		},
+		{
+			name:     "without v prefix",
+			input:    "0.10.0",
+			expected: &Version{Major: 0, Minor: 10, Patch: 0},
+		},
+		{
+			name:     "prerelease",
+			input:    "v1.2.0-rc.1",
+			expected: &Version{Major: 1, Minor: 2, Patch: 0, Prerelease: "rc.1"},
+		},
+		{
+			name:     "prerelease and build metadata",
+			input:    "v2.0.0-beta+build.5",
+			expected: &Version{Major: 2, Minor: 0, Patch: 0, Prerelease: "beta", Build: "build.5"},
+		},
+		{
+			name:    "missing patch",
+			input:   "v1.2",
+			wantErr: true,
+		},
+		{
+			name:    "leading zero",
+			input:   "v01.2.3",
+			wantErr: true,
+		},
+		{
+			name:    "not a version",
+			input:   "release-2024",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -1,14 +1,52 @@
 package util
 
 import (
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+	"maps"
+	"slices"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
+// hashFuncs defines the supported hash algorithms
+var hashFuncs = map[string]func() hash.Hash{
+	"sha256":   sha256.New,
+	"sha512":   sha512.New,
+	"sha1":     sha1.New,
+	"sha3-256": sha3.New256,
+	"crc32":    func() hash.Hash { return crc32.NewIEEE() },
+	"blake2b": func() hash.Hash {
+		h, _ := blake2b.New512(nil) // never fails without a key
+		return h
+	},
+}
+
+// HashAlgorithms returns the names of the supported hash algorithms in alphabetical order
+func HashAlgorithms() []string {
+	return slices.Sorted(maps.Keys(hashFuncs))
+}
+
 // CalculateSHA256 calculates SHA256 hash from an io.Reader
 func CalculateSHA256(r io.Reader) (string, error) {
-	hash := sha256.New()
+	return CalculateHash(r, "sha256")
+}
+
+// CalculateHash calculates the hash of an io.Reader with the given algorithm
+// (sha256, sha512, sha1, blake2b, sha3-256 or crc32)
+func CalculateHash(r io.Reader, algorithm string) (string, error) {
+	newHash, ok := hashFuncs[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+	}
+
+	hash := newHash()
 	if _, err := io.Copy(hash, r); err != nil {
 		return "", fmt.Errorf("failed to calculate hash: %w", err)
 	}
@@ -44,3 +44,69 @@ func Test_CalculateSHA256(t *testing.T) {
 		})
 	}
 }
+
+func Test_CalculateHash(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		input     string
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name:      "sha256",
+			algorithm: "sha256",
+			input:     "hello world",
+			expected:  "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		},
+		{
+			name:      "sha512",
+			algorithm: "sha512",
+			input:     "hello world",
+			expected:  "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f",
+		},
+		{
+			name:      "sha1",
+			algorithm: "sha1",
+			input:     "hello world",
+			expected:  "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+		},
+		{
+			name:      "sha3-256",
+			algorithm: "sha3-256",
+			input:     "hello world",
+			expected:  "644bcc7e564373040999aac89e7622f3ca71fba1d972fd94a31c3bfbf24e3938",
+		},
+		{
+			name:      "blake2b",
+			algorithm: "blake2b",
+			input:     "hello world",
+			expected:  "021ced8799296ceca557832ab941a50b4a11f83478cf141f51f933f653ab9fbcc05a037cddbed06e309bf334942c4e58cdf1a46e237911ccd7fcf9787cbc7fd0",
+		},
+		{
+			name:      "crc32",
+			algorithm: "crc32",
+			input:     "hello world",
+			expected:  "0d4a1185",
+		},
+		{
+			name:      "unsupported algorithm",
+			algorithm: "md5",
+			input:     "hello world",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := strings.NewReader(tt.input)
+			result, err := CalculateHash(reader, tt.algorithm)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"context"
+	"sync"
+)
+
+// Parallel runs fn for each index in [0, n) with at most limit concurrent calls.
+// The context passed to fn is cancelled as soon as any call fails, and the
+// first error is returned after all running calls have finished.
+func Parallel(ctx context.Context, limit, n int, fn func(ctx context.Context, i int) error) error {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, limit)
+
+	for i := range n {
+		// Wait for a free slot or stop scheduling on cancellation
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parallel(t *testing.T) {
+	t.Run("runs every index within the limit", func(t *testing.T) {
+		var running, maxRunning, calls int32
+		results := make([]int, 10)
+
+		err := Parallel(context.Background(), 3, len(results), func(ctx context.Context, i int) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			atomic.AddInt32(&calls, 1)
+			results[i] = i * 2
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(10), calls)
+		assert.LessOrEqual(t, maxRunning, int32(3))
+		for i, v := range results {
+			assert.Equal(t, i*2, v)
+		}
+	})
+
+	t.Run("returns the first error and cancels the others", func(t *testing.T) {
+		errBoom := errors.New("boom")
+
+		err := Parallel(context.Background(), 2, 100, func(ctx context.Context, i int) error {
+			if i == 0 {
+				return errBoom
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+	})
+}
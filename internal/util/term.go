@@ -0,0 +1,19 @@
+package util
+
+import (
+	"os"
+)
+
+// IsTerminal reports whether f is an interactive terminal.
+// CI environments are never treated as terminals, even if they allocate a TTY.
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("CI") != "" {
+		return false
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
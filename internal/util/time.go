@@ -0,0 +1,24 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SourceDate returns the build date.
+// SOURCE_DATE_EPOCH is respected so that builds can be reproduced.
+func SourceDate() (time.Time, error) {
+	env := os.Getenv("SOURCE_DATE_EPOCH")
+	if env == "" {
+		return time.Now(), nil
+	}
+
+	sec, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %s", env)
+	}
+
+	return time.Unix(sec, 0), nil
+}